@@ -1,24 +1,153 @@
 package gettext
 
+import (
+	"runtime"
+	"sync/atomic"
+
+	"github.com/snapcore/go-gettext/pluralforms"
+)
+
 // Catalog of translations for a given locale.
 type Catalog struct {
-	mos []*mocatalog
+	catalogs []msgCatalog
+	// refs is shared by copies of the catalog
+	refs *catalogRefs
+}
+
+// catalogRefs holds the references to file mappings that keep them
+// alive while a Catalog is in use.
+type catalogRefs struct {
+	closed atomic.Bool
+	// lookups counts the lookups in progress, so that close waits
+	// for them before releasing the mappings.  Lookups do not
+	// take a lock, so they never block each other, although
+	// concurrent lookups still share the counter's cache line.
+	lookups  atomic.Int64
+	mappings []*fileMapping
+}
+
+// newCatalog returns a Catalog of the given catalogs, acquiring a
+// reference to the file mapping of each mo catalog.  The references
+// are released by Close, or when the catalog and all its copies are
+// garbage collected.
+func newCatalog(catalogs []msgCatalog) Catalog {
+	refs := &catalogRefs{}
+	for _, catalog := range catalogs {
+		if mo, ok := catalog.(*mocatalog); ok {
+			mo.m.acquire()
+			refs.mappings = append(refs.mappings, mo.m)
+		}
+	}
+	if len(refs.mappings) != 0 {
+		runtime.SetFinalizer(refs, (*catalogRefs).close)
+	}
+	return Catalog{catalogs: catalogs, refs: refs}
+}
+
+func (refs *catalogRefs) close() error {
+	if !refs.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	// Lookups starting from now see the catalog as closed, so
+	// only those already in progress need to finish.
+	for refs.lookups.Load() != 0 {
+		runtime.Gosched()
+	}
+	runtime.SetFinalizer(refs, nil)
+	var firstErr error
+	for _, m := range refs.mappings {
+		if err := m.release(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close releases the memory mapped mo files used by the catalog.
+// Files are unmapped once no TextDomain cache or other Catalog uses
+// them.  Closing is optional: catalogs that are not closed release
+// their files when they are garbage collected.
+//
+// Close applies to all copies of the catalog, which return the
+// original messages once it is closed.  It is safe to call while
+// copies are in use by other goroutines, waiting for lookups in
+// progress to finish.
+func (c Catalog) Close() error {
+	if c.refs == nil {
+		return nil
+	}
+	return c.refs.close()
+}
+
+// msgCatalog is implemented by the parsed mo and po catalogs.
+type msgCatalog interface {
+	// findMsg looks up a message, returning the translation
+	// selected by form.
+	findMsg(msgid string, form form) (msgstr string, ok bool)
+	// useLanguageRule applies the standard plural rules for a
+	// language if the catalog does not declare its own.
+	useLanguageRule(language string)
+	// loadWarnings returns the problems that caused entries to be
+	// skipped when the catalog was read.
+	loadWarnings() []error
 }
 
-func (c Catalog) findMsg(msgid string, usePlural bool, n uint32) (msgstr string, ok bool) {
-	for _, mo := range c.mos {
-		if msgstr, ok := mo.findMsg(msgid, usePlural, n); ok {
+// formKind is the way the translation of a message is selected.
+type formKind int
+
+const (
+	singularForm formKind = iota
+	pluralForm
+	ordinalForm
+	decimalForm
+)
+
+// form selects one of the translations of a message, according to
+// the rules of the catalog holding it.
+type form struct {
+	kind formKind
+	// n is the count of plural and ordinal forms.
+	n uint64
+	// ops holds the operands of a decimal count.
+	ops pluralforms.Operands
+}
+
+func (c Catalog) findMsg(msgid string, form form) (msgstr string, ok bool) {
+	if c.refs != nil {
+		// The count is raised before checking closed, so that
+		// close either sees this lookup or it sees the catalog
+		// as closed.
+		c.refs.lookups.Add(1)
+		defer c.refs.lookups.Add(-1)
+		if c.refs.closed.Load() {
+			return "", false
+		}
+	}
+	for _, catalog := range c.catalogs {
+		if msgstr, ok := catalog.findMsg(msgid, form); ok {
 			return msgstr, true
 		}
 	}
 	return "", false
 }
 
+// Warnings returns the problems found in the catalog's files that did
+// not stop them from loading.  As with libintl, a plural entry whose
+// number of translations does not match the Plural-Forms header is
+// not fatal: the entry is skipped, so its original message is used.
+func (c Catalog) Warnings() []error {
+	var warnings []error
+	for _, catalog := range c.catalogs {
+		warnings = append(warnings, catalog.loadWarnings()...)
+	}
+	return warnings
+}
+
 // Gettext returns a translation of the provided message.
 //
 // If no translation is available, the original message is returned.
 func (c Catalog) Gettext(msgid string) string {
-	if msgstr, ok := c.findMsg(msgid, false, 0); ok {
+	if msgstr, ok := c.findMsg(msgid, form{}); ok {
 		return msgstr
 	}
 	// Fallback to original message
@@ -40,7 +169,31 @@ func (c Catalog) Gettext(msgid string) string {
 // be returned, according to the plural rule of Germanic languages
 // (i.e. msgid if n==1, and msgidPlural otherwise).
 func (c Catalog) NGettext(msgid, msgidPlural string, n uint32) string {
-	if msgstr, ok := c.findMsg(msgid, true, n); ok {
+	return c.npgettext(msgid, msgid, msgidPlural, uint64(n))
+}
+
+// NGettextUint64 is like NGettext, but accepts a 64-bit count such as
+// a file size.
+//
+// As with libintl on 64-bit systems, the plural expression is
+// evaluated with 64-bit unsigned arithmetic, so counts above 4 GiB
+// select the correct plural form rather than that of a truncated
+// count.
+func (c Catalog) NGettextUint64(msgid, msgidPlural string, n uint64) string {
+	return c.npgettext(msgid, msgid, msgidPlural, n)
+}
+
+// NGettextInt64 is like NGettext, but accepts a signed count.  The
+// plural form of a negative count is that of its absolute value, so
+// "-1 day" uses the same form as "1 day".
+func (c Catalog) NGettextInt64(msgid, msgidPlural string, n int64) string {
+	return c.npgettext(msgid, msgid, msgidPlural, absCount(n))
+}
+
+// npgettext looks up the plural message with the given key,
+// falling back to msgid or msgidPlural.
+func (c Catalog) npgettext(key, msgid, msgidPlural string, n uint64) string {
+	if msgstr, ok := c.findMsg(key, form{kind: pluralForm, n: n}); ok {
 		return msgstr
 	}
 	// Fallback to original message based on Germanic plural rule.
@@ -50,6 +203,15 @@ func (c Catalog) NGettext(msgid, msgidPlural string, n uint32) string {
 	return msgidPlural
 }
 
+// absCount returns the absolute value of a signed count.
+func absCount(n int64) uint64 {
+	if n < 0 {
+		// Negating as unsigned also handles math.MinInt64
+		return -uint64(n)
+	}
+	return uint64(n)
+}
+
 // PGettext returns a translation of the provided message using the
 // provided context.
 //
@@ -64,7 +226,7 @@ func (c Catalog) NGettext(msgid, msgidPlural string, n uint32) string {
 // If no translation is available, the original message is returned
 // without the context.
 func (c Catalog) PGettext(msgctxt, msgid string) string {
-	if msgstr, ok := c.findMsg(msgctxt+"\x04"+msgid, false, 0); ok {
+	if msgstr, ok := c.findMsg(msgctxt+"\x04"+msgid, form{}); ok {
 		return msgstr
 	}
 	return msgid
@@ -76,11 +238,88 @@ func (c Catalog) PGettext(msgctxt, msgid string) string {
 // This method combines the functionality of the NGettext and PGettext
 // variants.
 func (c Catalog) NPGettext(msgctxt, msgid, msgidPlural string, n uint32) string {
-	if msgstr, ok := c.findMsg(msgctxt+"\x04"+msgid, true, n); ok {
+	return c.npgettext(msgctxt+"\x04"+msgid, msgid, msgidPlural, uint64(n))
+}
+
+// NPGettextUint64 is like NPGettext, but accepts a 64-bit count, as
+// described for NGettextUint64.
+func (c Catalog) NPGettextUint64(msgctxt, msgid, msgidPlural string, n uint64) string {
+	return c.npgettext(msgctxt+"\x04"+msgid, msgid, msgidPlural, n)
+}
+
+// NPGettextInt64 is like NPGettext, but accepts a signed count, as
+// described for NGettextInt64.
+func (c Catalog) NPGettextInt64(msgctxt, msgid, msgidPlural string, n int64) string {
+	return c.npgettext(msgctxt+"\x04"+msgid, msgid, msgidPlural, absCount(n))
+}
+
+// Ordinal returns a translation of the provided ordinal message,
+// using the form for the ordinal number n.
+//
+// The forms are selected by the CLDR ordinal rules of the catalog's
+// language, so an English translation can distinguish "1st", "2nd",
+// "3rd" and "4th".  See OrdinalContext for how ordinal messages are
+// stored in catalogs:
+//
+//     fmt.Printf(c.Ordinal("%d.", place), place)
+//
+// If no translation is available, the original message is returned.
+func (c Catalog) Ordinal(msgid string, n uint32) string {
+	if msgstr, ok := c.findMsg(ordinalContext("")+"\x04"+msgid, form{kind: ordinalForm, n: uint64(n)}); ok {
 		return msgstr
 	}
-	// Fallback to original message based on Germanic plural rule.
-	if n == 1 {
+	return msgid
+}
+
+// POrdinal returns a translation of the provided ordinal message
+// using the provided context.
+//
+// This method combines the functionality of the Ordinal and PGettext
+// variants.
+func (c Catalog) POrdinal(msgctxt, msgid string, n uint32) string {
+	if msgstr, ok := c.findMsg(ordinalContext(msgctxt)+"\x04"+msgid, form{kind: ordinalForm, n: uint64(n)}); ok {
+		return msgstr
+	}
+	return msgid
+}
+
+// NGettextDecimal returns a translation of the provided message using
+// the plural form for a decimal number, such as "1.5" or "0.50".
+//
+// Plural forms in gettext catalogs are selected for integers, so
+// "1.5 hours" could be shown with the form for "1 hour".  When the
+// CLDR plural rules of the catalog's language are known, the form is
+// instead selected using the CLDR operands of n, which take the
+// fraction digits into account.  Decimal-only CLDR categories use
+// the form of the count 2.  Otherwise, or if the rules do not agree
+// with the catalog's Plural-Forms expression, the plural form of the
+// integer part of n is used.
+//
+// If no translation is available, msgid is returned if n is exactly
+// "1", and msgidPlural otherwise.  A malformed n always selects
+// msgidPlural.
+func (c Catalog) NGettextDecimal(msgid, msgidPlural, n string) string {
+	return c.npgettextDecimal(msgid, msgid, msgidPlural, n)
+}
+
+// NPGettextDecimal returns a translation of the provided message using
+// the provided context and the plural form for a decimal number.
+//
+// This method combines the functionality of the NGettextDecimal and
+// PGettext variants.
+func (c Catalog) NPGettextDecimal(msgctxt, msgid, msgidPlural, n string) string {
+	return c.npgettextDecimal(msgctxt+"\x04"+msgid, msgid, msgidPlural, n)
+}
+
+func (c Catalog) npgettextDecimal(key, msgid, msgidPlural, n string) string {
+	ops, err := pluralforms.ParseOperands(n)
+	if err != nil {
+		return msgidPlural
+	}
+	if msgstr, ok := c.findMsg(key, form{kind: decimalForm, ops: ops}); ok {
+		return msgstr
+	}
+	if ops.I == 1 && ops.V == 0 {
 		return msgid
 	}
 	return msgidPlural
@@ -0,0 +1,47 @@
+package gettext
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestNGettext64(t *testing.T) {
+	// A rule where truncating the count to 32 bits changes the
+	// plural form: 4294967297 truncates to 1.
+	catalog, err := ParsePO(strings.NewReader(`msgid ""
+msgstr "Plural-Forms: nplurals=3; plural=n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2;\n"
+
+msgid "%d byte"
+msgid_plural "%d bytes"
+msgstr[0] "%d bajt"
+msgstr[1] "%d bajty"
+msgstr[2] "%d bajtów"
+
+msgctxt "disk"
+msgid "%d byte"
+msgid_plural "%d bytes"
+msgstr[0] "%d bajt dysku"
+msgstr[1] "%d bajty dysku"
+msgstr[2] "%d bajtów dysku"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert_equal(t, catalog.NGettextUint64("%d byte", "%d bytes", 1), "%d bajt")
+	assert_equal(t, catalog.NGettextUint64("%d byte", "%d bytes", 4294967297), "%d bajtów")
+	assert_equal(t, catalog.NGettextUint64("%d byte", "%d bytes", 4294967302), "%d bajty")
+	assert_equal(t, catalog.NGettextUint64("%d byte", "%d bytes", math.MaxUint64), "%d bajtów")
+	assert_equal(t, catalog.NPGettextUint64("disk", "%d byte", "%d bytes", 4294967297), "%d bajtów dysku")
+
+	assert_equal(t, catalog.NGettextInt64("%d byte", "%d bytes", -1), "%d bajt")
+	assert_equal(t, catalog.NGettextInt64("%d byte", "%d bytes", -3), "%d bajty")
+	assert_equal(t, catalog.NGettextInt64("%d byte", "%d bytes", math.MinInt64), "%d bajtów")
+	assert_equal(t, catalog.NPGettextInt64("disk", "%d byte", "%d bytes", -22), "%d bajty dysku")
+
+	// Untranslated messages fall back to the Germanic rule
+	assert_equal(t, catalog.NGettextUint64("%d file", "%d files", 4294967297), "%d files")
+	assert_equal(t, catalog.NGettextInt64("%d file", "%d files", -1), "%d file")
+	assert_equal(t, catalog.NPGettextInt64("disk", "%d file", "%d files", 0), "%d files")
+}
@@ -0,0 +1,98 @@
+package gettext
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// charsetEncoding returns the encoding of a catalog's charset, or nil
+// if its strings are already UTF-8.
+func charsetEncoding(charset string) (encoding.Encoding, error) {
+	switch strings.ToLower(charset) {
+	// po templates carry a "CHARSET" placeholder, which msgfmt
+	// treats as ASCII
+	case "", "charset", "utf-8", "utf8", "ascii", "us-ascii", "ansi_x3.4-1968":
+		return nil, nil
+	}
+	// The WHATWG labels cover the common charset names, mapping
+	// legacy charsets such as ISO-8859-1 and GB2312 to their
+	// supersets.  The IANA registry covers the remainder.
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		enc, err = ianaindex.IANA.Encoding(charset)
+	}
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	if enc == unicode.UTF8 {
+		return nil, nil
+	}
+	// Catalogs are read as ASCII compatible text, which rules
+	// out stateful charsets that use ASCII bytes for other
+	// characters, and charsets with wide code units.  The WHATWG
+	// replacement encoding stands for charsets such as
+	// ISO-2022-KR.
+	name, _ := ianaindex.IANA.Name(enc)
+	if enc == encoding.Replacement || strings.HasPrefix(name, "ISO-2022-") || strings.HasPrefix(name, "UTF-16") {
+		return nil, fmt.Errorf("charset %q cannot be used in catalogs", charset)
+	}
+	return enc, nil
+}
+
+// multibyteLeadByte returns a function reporting the lead bytes of
+// the two byte characters of enc, for charsets where the second byte
+// can be a backslash.  A backslash following a lead byte is part of
+// the character rather than an escape in po strings.  Nil is returned
+// for other charsets.
+func multibyteLeadByte(enc encoding.Encoding) func(c byte) bool {
+	switch enc {
+	case japanese.ShiftJIS:
+		// Single bytes 0xa1-0xdf are half-width katakana
+		return func(c byte) bool {
+			return (c >= 0x81 && c <= 0x9f) || (c >= 0xe0 && c <= 0xfc)
+		}
+	case traditionalchinese.Big5, simplifiedchinese.GBK, simplifiedchinese.GB18030:
+		// The four byte sequences of GB18030 are read as pairs
+		// whose second byte is a digit
+		return func(c byte) bool {
+			return c >= 0x81 && c <= 0xfe
+		}
+	}
+	return nil
+}
+
+// decode converts a string of the catalog to UTF-8.  Bytes that
+// cannot be decoded are replaced by U+FFFD.
+func (catalog *catalogInfo) decode(s []byte) string {
+	if catalog.encoding == nil {
+		return string(s)
+	}
+	// Decoders hold state, so a new one is needed for each
+	// string to allow concurrent lookups.
+	decoder := catalog.encoding.NewDecoder()
+	var b strings.Builder
+	for {
+		decoded, n, err := transform.Bytes(decoder, s)
+		b.Write(decoded)
+		if err == nil {
+			break
+		}
+		// Replace the byte the decoder stopped at
+		b.WriteString("\uFFFD")
+		if n >= len(s) {
+			break
+		}
+		s = s[n+1:]
+		decoder.Reset()
+	}
+	return strings.ToValidUTF8(b.String(), "\uFFFD")
+}
@@ -0,0 +1,170 @@
+package gettext
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// encodedCatalogs returns a catalog translating "greeting" to text,
+// stored in the given charset as both a po and mo file.
+func encodedCatalogs(t *testing.T, charset string, enc encoding.Encoding, text string) []Catalog {
+	encoded, err := enc.NewEncoder().String(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := `msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=` + charset + `\n"
+
+msgid "greeting"
+msgstr "` + encoded + `"
+
+msgid "%d apple"
+msgid_plural "%d apples"
+msgstr[0] "` + encoded + ` 1"
+msgstr[1] "` + encoded + ` 2"
+`
+	return parsePOAndMO(t, data)
+}
+
+func TestCharsetConversion(t *testing.T) {
+	for _, test := range []struct {
+		charset string
+		enc     encoding.Encoding
+		text    string
+	}{
+		{"ISO-8859-1", charmap.ISO8859_1, "Grüß dich"},
+		{"iso-8859-2", charmap.ISO8859_2, "Dzień dobry"},
+		{"ISO-8859-15", charmap.ISO8859_15, "Bonjour, ça coûte 5 €"},
+		{"KOI8-R", charmap.KOI8R, "Здравствуйте"},
+		{"EUC-JP", japanese.EUCJP, "こんにちは"},
+		{"Shift_JIS", japanese.ShiftJIS, "こんにちは"},
+		{"GB2312", simplifiedchinese.GBK, "你好"},
+		{"BIG5", traditionalchinese.Big5, "你好"},
+		// Characters whose second byte is a backslash
+		{"Shift_JIS", japanese.ShiftJIS, "ソフトを表"},
+		{"Shift_JIS", japanese.ShiftJIS, "ｶﾀｶﾅ能"},
+		{"BIG5", traditionalchinese.Big5, "功能許"},
+	} {
+		for _, catalog := range encodedCatalogs(t, test.charset, test.enc, test.text) {
+			assert_equal(t, catalog.Gettext("greeting"), test.text)
+			assert_equal(t, catalog.NGettext("%d apple", "%d apples", 2), test.text+" 2")
+		}
+	}
+}
+
+func TestCharsetUTF8(t *testing.T) {
+	for _, charset := range []string{"UTF-8", "utf8", "CHARSET", "ASCII"} {
+		enc, err := charsetEncoding(charset)
+		if err != nil {
+			t.Errorf("charset %q: %v", charset, err)
+		}
+		if enc != nil {
+			t.Errorf("charset %q: expected no conversion", charset)
+		}
+	}
+}
+
+func TestCharsetUnknown(t *testing.T) {
+	data := `msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=X-UNKNOWN\n"
+
+msgid "greeting"
+msgstr "Hello"
+`
+	_, err := ParsePO(strings.NewReader(data))
+	if err == nil || err.Error() != `Content-Type header: unsupported charset "X-UNKNOWN"` {
+		t.Errorf("unexpected po error: %v", err)
+	}
+	messages, err := ReadPO(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := (&MOEncoder{}).Encode(&buf, messages); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseMOData(buf.Bytes())
+	if err == nil || err.Error() != `Content-Type header: unsupported charset "X-UNKNOWN"` {
+		t.Errorf("unexpected mo error: %v", err)
+	}
+}
+
+func TestCharsetMultibyteRoundTrip(t *testing.T) {
+	encoded, err := japanese.ShiftJIS.NewEncoder().String("ソフト\t表")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []Message{
+		{ID: "", Str: []string{"Content-Type: text/plain; charset=Shift_JIS\n"}},
+		{ID: "software", Str: []string{encoded}},
+	}
+	var buf bytes.Buffer
+	if err := WritePO(&buf, messages); err != nil {
+		t.Fatal(err)
+	}
+	// The second byte of a character is not escaped
+	if bytes.Contains(buf.Bytes(), []byte(`\\`)) {
+		t.Errorf("unexpected escapes in %q", buf.Bytes())
+	}
+	readBack, err := ReadPO(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, readBack, messages)
+}
+
+func TestCharsetUnsupported(t *testing.T) {
+	for _, charset := range []string{"ISO-2022-JP", "csISO2022JP", "ISO-2022-KR", "HZ-GB-2312", "UTF-16", "UTF-16BE", "UTF-7"} {
+		if _, err := charsetEncoding(charset); err == nil {
+			t.Errorf("charset %q: expected an error", charset)
+		}
+	}
+}
+
+// failingTransformer fails on 0xff bytes, and otherwise copies its
+// input.
+type failingTransformer struct {
+	transform.NopResetter
+}
+
+func (failingTransformer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		if src[nSrc] == 0xff {
+			return nDst, nSrc, errors.New("invalid byte")
+		}
+		if nDst >= len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		dst[nDst] = src[nSrc]
+		nDst++
+		nSrc++
+	}
+	return nDst, nSrc, nil
+}
+
+type failingEncoding struct{}
+
+func (failingEncoding) NewDecoder() *encoding.Decoder {
+	return &encoding.Decoder{Transformer: failingTransformer{}}
+}
+
+func (failingEncoding) NewEncoder() *encoding.Encoder {
+	return &encoding.Encoder{Transformer: failingTransformer{}}
+}
+
+func TestCharsetDecodeError(t *testing.T) {
+	catalog := &catalogInfo{encoding: failingEncoding{}}
+	assert_equal(t, catalog.decode([]byte("a\xffb\xff")), "a\uFFFDb\uFFFD")
+	assert_equal(t, catalog.decode([]byte("\x80ok")), "\uFFFDok")
+}
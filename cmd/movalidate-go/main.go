@@ -0,0 +1,80 @@
+// Command movalidate-go checks binary mo catalogs for problems.
+//
+// Each argument is a mo file, or a directory such as a locale tree
+// which is searched for mo files.  Every problem found by
+// gettext.ValidateMO is reported, and the exit status is 1 if there
+// were any, so that catalog packaging can be gated on the result.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/go-gettext"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	var quiet bool
+	flags := flag.NewFlagSet("movalidate-go", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: movalidate-go [OPTION] [FILE|DIR]...\n")
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&quiet, "q", false, "only report the number of invalid files")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	inputs := flags.Args()
+	if len(inputs) == 0 {
+		inputs = []string{gettext.DefaultLocaleDir}
+	}
+
+	checked, invalid := 0, 0
+	for _, input := range inputs {
+		err := filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Explicitly named files are checked whatever
+			// their name
+			if d.IsDir() || (path != input && !strings.HasSuffix(path, ".mo")) {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			checked++
+			problems := gettext.ValidateMO(data)
+			if len(problems) == 0 {
+				return nil
+			}
+			invalid++
+			if !quiet {
+				for _, p := range problems {
+					fmt.Fprintf(stdout, "%s: %s\n", path, p)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "movalidate-go: %v\n", err)
+			return 1
+		}
+	}
+	if invalid != 0 {
+		fmt.Fprintf(stderr, "movalidate-go: %d of %d files invalid\n", invalid, checked)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTree(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"../../testdata"}, &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	dir := t.TempDir()
+	data, err := ioutil.ReadFile("../../testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "en", "LC_MESSAGES"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	good := filepath.Join(dir, "en", "LC_MESSAGES", "good.mo")
+	if err := ioutil.WriteFile(good, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "en", "LC_MESSAGES", "bad.mo")
+	if err := ioutil.WriteFile(bad, data[:len(data)-1], 0666); err != nil {
+		t.Fatal(err)
+	}
+	// Files without the mo extension are skipped
+	if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("hello"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{dir}, &stdout, &stderr)
+	if status != 1 {
+		t.Errorf("expected exit status 1, got %d", status)
+	}
+	if !strings.HasPrefix(stdout.String(), bad+": offset ") || !strings.Contains(stdout.String(), "unterminated-string") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+	if strings.Contains(stdout.String(), good) {
+		t.Errorf("valid file reported:\n%s", stdout.String())
+	}
+	if stderr.String() != "movalidate-go: 1 of 2 files invalid\n" {
+		t.Errorf("unexpected error output: %q", stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	status = run([]string{"-q", bad}, &stdout, &stderr)
+	if status != 1 || stdout.Len() != 0 {
+		t.Errorf("unexpected result %d:\n%s", status, stdout.String())
+	}
+	if stderr.String() != "movalidate-go: 1 of 1 files invalid\n" {
+		t.Errorf("unexpected error output: %q", stderr.String())
+	}
+}
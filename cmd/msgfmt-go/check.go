@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/go-gettext"
+)
+
+// checkMessages performs the checks of msgfmt --check on the messages
+// that will be written to the mo file, returning a description of
+// each problem found.
+func checkMessages(messages []gettext.Message, useFuzzy bool) []string {
+	var problems []string
+	var header *gettext.Message
+	hasPlurals := false
+	for i := range messages {
+		msg := &messages[i]
+		if msg.Obsolete {
+			continue
+		}
+		if msg.IsHeader() {
+			header = msg
+		} else if msg.IDPlural != "" {
+			hasPlurals = true
+		}
+	}
+
+	nplurals := 0
+	if header == nil {
+		problems = append(problems, "header entry missing")
+	} else if h, err := gettext.ParseHeader(header.Str[0]); err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		if _, ok := h.Fields["content-type"]; !ok {
+			problems = append(problems, "header field 'Content-Type' missing")
+		} else if h.Charset == "" || h.Charset == "CHARSET" {
+			problems = append(problems, "header field 'Content-Type' does not specify a valid charset")
+		}
+		if h.Plural != nil {
+			nplurals = h.NPlurals
+		} else if hasPlurals {
+			problems = append(problems, "header field 'Plural-Forms' missing, but messages use plural forms")
+		}
+	}
+
+	for i := range messages {
+		msg := &messages[i]
+		if msg.IsHeader() || !include(msg, useFuzzy) {
+			continue
+		}
+		for _, problem := range checkMessage(msg, nplurals) {
+			problems = append(problems, fmt.Sprintf("message %q: %s", msg.ID, problem))
+		}
+	}
+	return problems
+}
+
+func checkMessage(msg *gettext.Message, nplurals int) []string {
+	var problems []string
+	// Ordinal messages have one form per CLDR ordinal category,
+	// rather than nplurals forms.
+	if msg.IDPlural != "" && nplurals != 0 && len(msg.Str) != nplurals && !msg.IsOrdinal() {
+		problems = append(problems, fmt.Sprintf("has %d plural forms, but nplurals=%d", len(msg.Str), nplurals))
+	}
+
+	for i, str := range msg.Str {
+		name := "msgstr"
+		id := msg.ID
+		if msg.IDPlural != "" {
+			name = fmt.Sprintf("msgstr[%d]", i)
+			if i != 0 || nplurals == 1 {
+				id = msg.IDPlural
+			}
+		}
+		if strings.HasPrefix(id, "\n") != strings.HasPrefix(str, "\n") {
+			problems = append(problems, fmt.Sprintf("msgid and %s do not both begin with '\\n'", name))
+		}
+		if strings.HasSuffix(id, "\n") != strings.HasSuffix(str, "\n") {
+			problems = append(problems, fmt.Sprintf("msgid and %s do not both end with '\\n'", name))
+		}
+		if msg.HasFlag("c-format") || msg.HasFlag("go-format") {
+			want := formatDirectives(id)
+			got := formatDirectives(str)
+			if strings.Join(want, " ") != strings.Join(got, " ") {
+				problems = append(problems, fmt.Sprintf("format directives of %s (%s) do not match msgid (%s)", name, strings.Join(got, " "), strings.Join(want, " ")))
+			}
+		}
+	}
+	return problems
+}
+
+type directive struct {
+	arg  int
+	conv string
+}
+
+// formatDirectives returns the conversions of the printf style format
+// string, ordered by the argument they consume.  Both C style (%1$d)
+// and Go style (%[1]d) explicit argument indexes are understood.
+func formatDirectives(format string) []string {
+	var directives []directive
+	arg := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		if i < len(format) && format[i] == '%' {
+			continue
+		}
+		// Explicit argument index
+		j := i
+		for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+			j++
+		}
+		if j > i && j < len(format) && format[j] == '$' {
+			arg, _ = strconv.Atoi(format[i:j])
+			arg--
+			i = j + 1
+		} else if i < len(format) && format[i] == '[' {
+			if end := strings.IndexByte(format[i:], ']'); end > 0 {
+				if n, err := strconv.Atoi(format[i+1 : i+end]); err == nil {
+					arg = n - 1
+				}
+				i += end + 1
+			}
+		}
+		// Flags, width and precision
+		for i < len(format) && strings.IndexByte("-+ #0'*.123456789", format[i]) >= 0 {
+			if format[i] == '*' {
+				directives = append(directives, directive{arg: arg, conv: "*"})
+				arg++
+			}
+			i++
+		}
+		// Length modifiers
+		start := i
+		for i < len(format) && strings.IndexByte("hlLqjzt", format[i]) >= 0 {
+			i++
+		}
+		if i >= len(format) {
+			directives = append(directives, directive{arg: arg, conv: "!"})
+			break
+		}
+		directives = append(directives, directive{arg: arg, conv: format[start : i+1]})
+		arg++
+	}
+	sort.SliceStable(directives, func(i, j int) bool {
+		return directives[i].arg < directives[j].arg
+	})
+	convs := make([]string, len(directives))
+	for i, d := range directives {
+		convs[i] = d.conv
+	}
+	return convs
+}
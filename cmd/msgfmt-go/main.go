@@ -0,0 +1,189 @@
+// Command msgfmt-go compiles po files into binary mo catalogs.
+//
+// It supports the commonly used options of GNU msgfmt, and produces
+// catalogs that can be read by both this package and GNU gettext.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/snapcore/go-gettext"
+)
+
+type options struct {
+	output     string
+	check      bool
+	statistics bool
+	endianness string
+	noHash     bool
+	useFuzzy   bool
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var opts options
+	flags := flag.NewFlagSet("msgfmt-go", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: msgfmt-go [OPTION] filename.po ...\n")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&opts.output, "o", "messages.mo", "write output to `file`, or standard output if -")
+	flags.StringVar(&opts.output, "output-file", "messages.mo", "write output to `file`, or standard output if -")
+	flags.BoolVar(&opts.check, "c", false, "perform additional checks on the input")
+	flags.BoolVar(&opts.check, "check", false, "perform additional checks on the input")
+	flags.BoolVar(&opts.statistics, "statistics", false, "print statistics about translations")
+	flags.StringVar(&opts.endianness, "endianness", "little", "byte order of the output: big or little")
+	flags.BoolVar(&opts.noHash, "no-hash", false, "do not include a hash table in the output")
+	flags.BoolVar(&opts.useFuzzy, "f", false, "include fuzzy entries in the output")
+	flags.BoolVar(&opts.useFuzzy, "use-fuzzy", false, "include fuzzy entries in the output")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "msgfmt-go: no input file given")
+		return 2
+	}
+
+	encoder := &gettext.MOEncoder{NoHash: opts.noHash}
+	switch opts.endianness {
+	case "little":
+		encoder.ByteOrder = binary.LittleEndian
+	case "big":
+		encoder.ByteOrder = binary.BigEndian
+	default:
+		fmt.Fprintf(stderr, "msgfmt-go: invalid endianness: %s\n", opts.endianness)
+		return 2
+	}
+
+	var messages []gettext.Message
+	var stats statistics
+	failed := false
+	hasHeader := false
+	for _, filename := range flags.Args() {
+		fileMessages, err := readPO(filename, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "msgfmt-go: %s: %v\n", filename, err)
+			failed = true
+			continue
+		}
+		if opts.check {
+			for _, problem := range checkMessages(fileMessages, opts.useFuzzy) {
+				fmt.Fprintf(stderr, "msgfmt-go: %s: %s\n", filename, problem)
+				failed = true
+			}
+		}
+		for _, msg := range fileMessages {
+			if msg.Obsolete {
+				continue
+			}
+			if msg.IsHeader() {
+				// When compiling several files, the header
+				// of the first one is used
+				if hasHeader {
+					continue
+				}
+				hasHeader = true
+			} else {
+				stats.add(&msg)
+			}
+			if include(&msg, opts.useFuzzy) {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	if opts.statistics {
+		fmt.Fprintln(stderr, stats)
+	}
+	if failed {
+		return 1
+	}
+
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, messages); err != nil {
+		fmt.Fprintf(stderr, "msgfmt-go: %v\n", err)
+		return 1
+	}
+	if opts.output == "-" {
+		_, err := stdout.Write(buf.Bytes())
+		if err != nil {
+			fmt.Fprintf(stderr, "msgfmt-go: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if err := ioutil.WriteFile(opts.output, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(stderr, "msgfmt-go: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func readPO(filename string, stdin io.Reader) ([]gettext.Message, error) {
+	if filename == "-" {
+		return gettext.ReadPO(stdin)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return gettext.ReadPO(f)
+}
+
+// include returns true if the message should be written to the mo
+// file.  The header entry is always kept, even if it is fuzzy.
+func include(msg *gettext.Message, useFuzzy bool) bool {
+	if msg.IsHeader() {
+		return true
+	}
+	if !msg.IsTranslated() {
+		return false
+	}
+	return useFuzzy || !msg.HasFlag("fuzzy")
+}
+
+type statistics struct {
+	translated   int
+	fuzzy        int
+	untranslated int
+}
+
+func (s *statistics) add(msg *gettext.Message) {
+	switch {
+	case !msg.IsTranslated():
+		s.untranslated++
+	case msg.HasFlag("fuzzy"):
+		s.fuzzy++
+	default:
+		s.translated++
+	}
+}
+
+// String formats the statistics in the same way as GNU msgfmt.
+func (s statistics) String() string {
+	str := plural(s.translated, "translated message", "translated messages")
+	if s.fuzzy != 0 {
+		str += ", " + plural(s.fuzzy, "fuzzy translation", "fuzzy translations")
+	}
+	if s.untranslated != 0 {
+		str += ", " + plural(s.untranslated, "untranslated message", "untranslated messages")
+	}
+	return str + "."
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
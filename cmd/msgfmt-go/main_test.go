@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snapcore/go-gettext"
+)
+
+func TestCompileTestdata(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		args []string
+		mo   string
+	}{
+		{[]string{"../../testdata/en/messages.po"}, "../../testdata/en/messages.mo"},
+		{[]string{"--endianness=big", "../../testdata/en/messages.po"}, "../../testdata/en/messages-be.mo"},
+		{[]string{"--no-hash", "../../testdata/en/messages.po"}, "../../testdata/en/messages-nohash.mo"},
+		{[]string{"-c", "../../testdata/es/messages.po"}, "../../testdata/es/messages.mo"},
+		{[]string{"--check", "../../testdata/ja/messages.po"}, "../../testdata/ja/messages.mo"},
+	} {
+		output := filepath.Join(dir, "out.mo")
+		var stderr bytes.Buffer
+		status := run(append([]string{"-o", output}, test.args...), nil, ioutil.Discard, &stderr)
+		if status != 0 {
+			t.Fatalf("%v: exit status %d: %s", test.args, status, stderr.String())
+		}
+		got, err := ioutil.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := ioutil.ReadFile(test.mo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Errorf("%v: output does not match %s", test.args, test.mo)
+		}
+	}
+}
+
+const testPO = `msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+msgid "translated"
+msgstr "übersetzt"
+
+#, fuzzy
+msgid "fuzzy"
+msgstr "unscharf"
+
+msgid "untranslated"
+msgstr ""
+
+#~ msgid "obsolete"
+#~ msgstr "veraltet"
+`
+
+func TestStatisticsAndFuzzy(t *testing.T) {
+	for _, test := range []struct {
+		args     []string
+		hasFuzzy bool
+	}{
+		{[]string{"--statistics", "-o", "-", "-"}, false},
+		{[]string{"--statistics", "--use-fuzzy", "-o", "-", "-"}, true},
+	} {
+		var stdout, stderr bytes.Buffer
+		status := run(test.args, strings.NewReader(testPO), &stdout, &stderr)
+		if status != 0 {
+			t.Fatalf("exit status %d: %s", status, stderr.String())
+		}
+		if stderr.String() != "1 translated message, 1 fuzzy translation, 1 untranslated message.\n" {
+			t.Errorf("unexpected statistics: %q", stderr.String())
+		}
+		if !bytes.Contains(stdout.Bytes(), []byte("übersetzt")) {
+			t.Errorf("translated message missing from output")
+		}
+		if bytes.Contains(stdout.Bytes(), []byte("unscharf")) != test.hasFuzzy {
+			t.Errorf("%v: unexpected handling of fuzzy message", test.args)
+		}
+		if bytes.Contains(stdout.Bytes(), []byte("veraltet")) {
+			t.Errorf("obsolete message included in output")
+		}
+	}
+}
+
+func TestMultipleInputs(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.po")
+	second := filepath.Join(dir, "second.po")
+	if err := ioutil.WriteFile(first, []byte(`msgid ""
+msgstr "Content-Type: text/plain; charset=UTF-8\n"
+
+msgid "one"
+msgstr "eins"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(second, []byte(`msgid ""
+msgstr "Content-Type: text/plain; charset=ISO-8859-1\n"
+
+msgid "two"
+msgstr "zwei"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"-o", "-", first, second}, nil, &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	catalog, err := gettext.ParseMOData(stdout.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := catalog.Gettext("one"); got != "eins" {
+		t.Errorf("expected \"eins\", got %q", got)
+	}
+	if got := catalog.Gettext("two"); got != "zwei" {
+		t.Errorf("expected \"zwei\", got %q", got)
+	}
+	// Only the first header is kept
+	if bytes.Contains(stdout.Bytes(), []byte("ISO-8859-1")) {
+		t.Errorf("header of second file included in output")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var stderr bytes.Buffer
+	status := run([]string{"--check", "-o", "-", "-"}, strings.NewReader(`msgid ""
+msgstr "Content-Type: text/plain; charset=CHARSET\n"
+
+#, c-format
+msgid "%d file\n"
+msgid_plural "%d files\n"
+msgstr[0] "%s Datei\n"
+msgstr[1] "%d Dateien"
+`), ioutil.Discard, &stderr)
+	if status != 1 {
+		t.Errorf("expected exit status 1, got %d", status)
+	}
+	expected := []string{
+		"msgfmt-go: -: header field 'Content-Type' does not specify a valid charset",
+		"msgfmt-go: -: header field 'Plural-Forms' missing, but messages use plural forms",
+		`msgfmt-go: -: message "%d file\n": format directives of msgstr[0] (s) do not match msgid (d)`,
+		`msgfmt-go: -: message "%d file\n": msgid and msgstr[1] do not both end with '\n'`,
+		"",
+	}
+	if stderr.String() != strings.Join(expected, "\n") {
+		t.Errorf("unexpected output:\n%s", stderr.String())
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	// The header is checked as the catalog loader reads it
+	for _, test := range []struct {
+		header  string
+		problem string
+	}{
+		{`Content-Type: text/plain; charset=X-UNKNOWN\n`, `Content-Type header: unsupported charset "X-UNKNOWN"`},
+		{`Content-Type: text/plain; charset=UTF-8\nPlural-Forms: nplurals=2; nplurals=3; plural=n != 1;\n`, "Plural-Forms header: duplicate nplurals parameter"},
+		{`Content-Type: text/plain; charset=UTF-8\nPlural-Forms: nplurals=2; plural=n;\n`, "Plural-Forms header: plural expression selects form 2 for n = 2, but nplurals = 2"},
+	} {
+		var stderr bytes.Buffer
+		status := run([]string{"--check", "-o", "-", "-"}, strings.NewReader(`msgid ""
+msgstr "`+test.header+`"
+`), ioutil.Discard, &stderr)
+		if status != 1 {
+			t.Errorf("%s: expected exit status 1, got %d", test.header, status)
+		}
+		if expected := "msgfmt-go: -: " + test.problem + "\n"; stderr.String() != expected {
+			t.Errorf("%s: unexpected output:\n%s", test.header, stderr.String())
+		}
+	}
+}
+
+func TestBadArguments(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"--endianness=middle", "messages.po"},
+		{"--unknown-flag", "messages.po"},
+	} {
+		if status := run(args, nil, ioutil.Discard, ioutil.Discard); status != 2 {
+			t.Errorf("%v: expected exit status 2, got %d", args, status)
+		}
+	}
+	if status := run([]string{"does-not-exist.po"}, nil, ioutil.Discard, ioutil.Discard); status != 1 {
+		t.Errorf("expected exit status 1 for missing file, got %d", status)
+	}
+}
+
+func TestFormatDirectives(t *testing.T) {
+	for _, test := range []struct {
+		format   string
+		expected string
+	}{
+		{"no directives", ""},
+		{"100%% done", ""},
+		{"%d of %s", "d s"},
+		{"%2$s %1$d", "d s"},
+		{"%[2]s %[1]d", "d s"},
+		{"%-5.2f %lu %*d", "f lu * d"},
+		{"trailing %", "!"},
+	} {
+		got := strings.Join(formatDirectives(test.format), " ")
+		if got != test.expected {
+			t.Errorf("formatDirectives(%q) = %q, expected %q", test.format, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Command msgunfmt-go decompiles binary mo catalogs into po files.
+//
+// Each entry of the catalogs is written out, including the header
+// entry which is preserved verbatim.  When several catalogs are given,
+// entries already defined by an earlier catalog, including the header,
+// are skipped.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/snapcore/go-gettext"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var output string
+	flags := flag.NewFlagSet("msgunfmt-go", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: msgunfmt-go [OPTION] [FILE]...\n")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&output, "o", "-", "write output to `file`, or standard output if -")
+	flags.StringVar(&output, "output-file", "-", "write output to `file`, or standard output if -")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	inputs := flags.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	var messages []gettext.Message
+	seen := make(map[string]bool)
+	for _, filename := range inputs {
+		fileMessages, err := readMO(filename, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "msgunfmt-go: %s: %v\n", filename, err)
+			return 1
+		}
+		for _, msg := range fileMessages {
+			key := messageKey(&msg)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			messages = append(messages, msg)
+		}
+	}
+
+	if err := writePO(output, stdout, messages); err != nil {
+		fmt.Fprintf(stderr, "msgunfmt-go: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// messageKey returns the key identifying a message in a catalog.
+func messageKey(msg *gettext.Message) string {
+	if msg.HasContext {
+		return msg.Context + "\x04" + msg.ID
+	}
+	return msg.ID
+}
+
+func readMO(filename string, stdin io.Reader) ([]gettext.Message, error) {
+	if filename == "-" {
+		return gettext.ReadMO(stdin)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return gettext.ReadMO(f)
+}
+
+// writePO writes messages to the named file, or to stdout if the name
+// is "-".  Errors closing the file are reported, as they may mean the
+// output was not written.
+func writePO(filename string, stdout io.Writer, messages []gettext.Message) error {
+	if filename == "-" {
+		return gettext.WritePO(stdout, messages)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := gettext.WritePO(f, messages); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snapcore/go-gettext"
+)
+
+func TestDecompile(t *testing.T) {
+	for _, mo := range []string{"messages.mo", "messages-be.mo", "messages-nohash.mo"} {
+		var stdout, stderr bytes.Buffer
+		status := run([]string{"../../testdata/en/" + mo}, nil, &stdout, &stderr)
+		if status != 0 {
+			t.Fatalf("exit status %d: %s", status, stderr.String())
+		}
+		// The catalog's entries are in the same order as the
+		// po file it was compiled from.
+		expected, err := ioutil.ReadFile("../../testdata/en/messages.po")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stdout.String() != string(expected) {
+			t.Errorf("%s: unexpected output:\n%s", mo, stdout.String())
+		}
+	}
+}
+
+func TestDecompileContext(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.po")
+	var stderr bytes.Buffer
+	status := run([]string{"-o", output, "../../testdata/es/messages.mo"}, nil, ioutil.Discard, &stderr)
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	got, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `
+msgctxt "knot"
+msgid "%d bow"
+msgid_plural "%d bows"
+msgstr[0] "%d lazo"
+msgstr[1] "%d lazos"
+`) {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
+
+func TestDecompileMultiple(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"../../testdata/en_AU/messages.mo", "../../testdata/en/messages.mo"}, nil, &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	messages, err := gettext.ReadPO(&stdout)
+	if err != nil {
+		t.Fatalf("output is not a valid po file: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, msg := range messages {
+		key := messageKey(&msg)
+		if seen[key] {
+			t.Errorf("duplicate message %q", key)
+		}
+		seen[key] = true
+		// The first catalog's translation is kept
+		if msg.ID == "greeting" && msg.Str[0] != "G'day" {
+			t.Errorf("unexpected translation of greeting: %q", msg.Str[0])
+		}
+	}
+	if !seen[""] {
+		t.Errorf("header entry missing from output")
+	}
+}
+
+func TestDecompileStdin(t *testing.T) {
+	f, err := os.Open("../../testdata/en_AU/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var stdout bytes.Buffer
+	if status := run(nil, f, &stdout, ioutil.Discard); status != 0 {
+		t.Fatalf("exit status %d", status)
+	}
+	if !strings.HasSuffix(stdout.String(), "\nmsgid \"greeting\"\nmsgstr \"G'day\"\n") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestDecompileInvalid(t *testing.T) {
+	var stderr bytes.Buffer
+	status := run([]string{"../../testdata/en/messages.po"}, nil, ioutil.Discard, &stderr)
+	if status != 1 {
+		t.Errorf("expected exit status 1, got %d", status)
+	}
+	if !strings.HasPrefix(stderr.String(), "msgunfmt-go: ../../testdata/en/messages.po: Wrong magic") {
+		t.Errorf("unexpected error: %s", stderr.String())
+	}
+}
+
+func TestDecompileBrokenCatalog(t *testing.T) {
+	// Catalogs the loader rejects can still be decompiled
+	for _, header := range []string{
+		"Content-Type: text/plain; charset=UTF-8\nPlural-Forms: nplurals=3; plural=n%3;\n",
+		"Content-Type: text/plain; charset=X-UNKNOWN\n",
+	} {
+		var mo bytes.Buffer
+		err := (&gettext.MOEncoder{}).Encode(&mo, []gettext.Message{
+			{Str: []string{header}},
+			{ID: "file", IDPlural: "files", Str: []string{"fichier", "fichiers"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		if status := run(nil, &mo, &stdout, &stderr); status != 0 {
+			t.Fatalf("exit status %d: %s", status, stderr.String())
+		}
+		if !strings.HasSuffix(stdout.String(), "msgstr[0] \"fichier\"\nmsgstr[1] \"fichiers\"\n") {
+			t.Errorf("unexpected output:\n%s", stdout.String())
+		}
+	}
+}
+
+func TestDecompileWriteError(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("/dev/full is not available")
+	}
+	var stderr bytes.Buffer
+	status := run([]string{"-o", "/dev/full", "../../testdata/en/messages.mo"}, nil, ioutil.Discard, &stderr)
+	if status != 1 {
+		t.Errorf("expected exit status 1, got %d", status)
+	}
+	if !strings.HasPrefix(stderr.String(), "msgunfmt-go: ") {
+		t.Errorf("unexpected error: %s", stderr.String())
+	}
+}
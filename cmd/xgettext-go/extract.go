@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/go-gettext"
+)
+
+// formatDirective matches a printf style verb, used to mark messages
+// as format strings.
+var formatDirective = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*(\d+|\*)?(\.(\d+|\*))?[a-zA-Z]`)
+
+// extractor collects translatable messages from Go source files.
+type extractor struct {
+	keywords   map[string]keyword
+	commentTag string
+
+	fset     *token.FileSet
+	messages []gettext.Message
+	index    map[string]int
+	warnings []string
+}
+
+func newExtractor(keywords []keyword, commentTag string) *extractor {
+	e := &extractor{
+		keywords:   make(map[string]keyword),
+		commentTag: commentTag,
+		fset:       token.NewFileSet(),
+		index:      make(map[string]int),
+	}
+	for _, kw := range keywords {
+		e.keywords[kw.name] = kw
+	}
+	return e
+}
+
+func (e *extractor) warnf(pos token.Pos, format string, args ...interface{}) {
+	position := e.fset.Position(pos)
+	e.warnings = append(e.warnings, fmt.Sprintf("%s:%d: %s", filepath.ToSlash(position.Filename), position.Line, fmt.Sprintf(format, args...)))
+}
+
+// extractFile extracts the messages of a Go source file.  If src is
+// nil, the file is read from disk.
+func (e *extractor) extractFile(filename string, src interface{}) error {
+	file, err := parser.ParseFile(e.fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	// Index comments by the line they end on, so the comment
+	// preceding a call can be found.
+	comments := make(map[int]*ast.CommentGroup)
+	for _, group := range file.Comments {
+		comments[e.fset.Position(group.End()).Line] = group
+	}
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var name string
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			name = fun.Name
+		case *ast.SelectorExpr:
+			name = fun.Sel.Name
+		default:
+			return true
+		}
+		kw, ok := e.keywords[name]
+		if !ok {
+			return true
+		}
+		e.extractCall(call, kw, comments)
+		return true
+	})
+	return nil
+}
+
+func (e *extractor) extractCall(call *ast.CallExpr, kw keyword, comments map[int]*ast.CommentGroup) {
+	msg := gettext.Message{HasContext: kw.context != 0 || kw.ordinal}
+	for _, arg := range []struct {
+		pos   int
+		value *string
+	}{
+		{kw.context, &msg.Context},
+		{kw.msgid, &msg.ID},
+		{kw.msgidPlural, &msg.IDPlural},
+	} {
+		if arg.pos == 0 {
+			continue
+		}
+		if arg.pos > len(call.Args) {
+			e.warnf(call.Pos(), "too few arguments in call to %s", kw.name)
+			return
+		}
+		value, ok := stringConstant(call.Args[arg.pos-1])
+		if !ok {
+			e.warnf(call.Args[arg.pos-1].Pos(), "argument %d of %s is not a string literal", arg.pos, kw.name)
+			return
+		}
+		*arg.value = value
+	}
+	if msg.ID == "" {
+		e.warnf(call.Pos(), "empty msgid in call to %s is reserved for the catalog header", kw.name)
+		return
+	}
+	if kw.ordinal {
+		// Ordinal messages are plural messages with a
+		// reserved context
+		if msg.Context != "" {
+			msg.Context = gettext.OrdinalContext + ":" + msg.Context
+		} else {
+			msg.Context = gettext.OrdinalContext
+		}
+		msg.IDPlural = msg.ID
+	}
+
+	position := e.fset.Position(call.Pos())
+	reference := fmt.Sprintf("%s:%d", filepath.ToSlash(position.Filename), position.Line)
+	var extracted []string
+	if e.commentTag != "" {
+		// Look for a comment on the same line or the line
+		// before the call.
+		group := comments[position.Line]
+		if group == nil || group.Pos() > call.Pos() {
+			group = comments[position.Line-1]
+		}
+		if group != nil {
+			extracted = e.taggedComment(group)
+		}
+	}
+	e.add(msg, reference, extracted)
+}
+
+// taggedComment returns the lines of the comment starting from the
+// comment tag, or nil if the comment has no tag.
+func (e *extractor) taggedComment(group *ast.CommentGroup) []string {
+	text := group.Text()
+	pos := strings.Index(text, e.commentTag)
+	if pos < 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(text[pos:]), "\n")
+}
+
+// add records an extracted message, merging it with any earlier
+// occurrence of the same message.
+func (e *extractor) add(msg gettext.Message, reference string, extracted []string) {
+	key := msg.ID
+	if msg.HasContext {
+		key = msg.Context + "\x04" + msg.ID
+	}
+	idx, ok := e.index[key]
+	if !ok {
+		idx = len(e.messages)
+		e.index[key] = idx
+		if msg.IDPlural != "" {
+			msg.Str = []string{"", ""}
+		} else {
+			msg.Str = []string{""}
+		}
+		if isFormatString(msg.ID) || isFormatString(msg.IDPlural) {
+			msg.Flags = []string{"go-format"}
+		}
+		e.messages = append(e.messages, msg)
+	}
+	existing := &e.messages[idx]
+	if msg.IDPlural != "" && existing.IDPlural == "" {
+		existing.IDPlural = msg.IDPlural
+		existing.Str = []string{"", ""}
+	}
+	existing.References = append(existing.References, reference)
+	for _, line := range extracted {
+		if !contains(existing.ExtractedComments, line) {
+			existing.ExtractedComments = append(existing.ExtractedComments, line)
+		}
+	}
+}
+
+// isFormatString returns true if s contains printf style verbs.
+func isFormatString(s string) bool {
+	return formatDirective.MatchString(strings.Replace(s, "%%", "", -1))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// stringConstant returns the value of a string literal, or of a
+// concatenation of string literals.
+func stringConstant(expr ast.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		if expr.Kind != token.STRING {
+			return "", false
+		}
+		value, err := strconv.Unquote(expr.Value)
+		if err != nil {
+			return "", false
+		}
+		return value, true
+	case *ast.BinaryExpr:
+		if expr.Op != token.ADD {
+			return "", false
+		}
+		left, ok := stringConstant(expr.X)
+		if !ok {
+			return "", false
+		}
+		right, ok := stringConstant(expr.Y)
+		if !ok {
+			return "", false
+		}
+		return left + right, true
+	case *ast.ParenExpr:
+		return stringConstant(expr.X)
+	}
+	return "", false
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/snapcore/go-gettext"
+)
+
+const testSource = `package example
+
+import (
+	"fmt"
+
+	"github.com/snapcore/go-gettext"
+)
+
+func i18n(s string) string { return s }
+
+func example(c gettext.Catalog, n uint32, s string) {
+	// TRANSLATORS: shown on startup
+	fmt.Println(c.Gettext("Hello"))
+
+	// An ordinary comment
+	fmt.Println(c.Gettext("Hello"))
+
+	fmt.Printf(c.NGettext("%d file", "%d files", n), n)
+	fmt.Println(c.PGettext("menu", "Open"))
+	fmt.Println(c.NPGettext("menu", "Recent file", ` + "`Recent files`" + `, n))
+	fmt.Println(c.Gettext("multi " +
+		"part"))
+	fmt.Println(i18n("wrapped"))
+	fmt.Println(c.Gettext(s))
+	fmt.Println(c.Gettext("100%% done"))
+	fmt.Printf(c.Ordinal("%d.", n), n)
+	fmt.Printf(c.POrdinal("race", "%d. place", n), n)
+}
+`
+
+func TestExtract(t *testing.T) {
+	kw, err := parseKeyword("i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newExtractor(append(defaultKeywords, kw), "TRANSLATORS:")
+	if err := e.extractFile("example.go", testSource); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []gettext.Message{{
+		ID:                "Hello",
+		Str:               []string{""},
+		ExtractedComments: []string{"TRANSLATORS: shown on startup"},
+		References:        []string{"example.go:13", "example.go:16"},
+	}, {
+		ID:         "%d file",
+		IDPlural:   "%d files",
+		Str:        []string{"", ""},
+		References: []string{"example.go:18"},
+		Flags:      []string{"go-format"},
+	}, {
+		Context:    "menu",
+		HasContext: true,
+		ID:         "Open",
+		Str:        []string{""},
+		References: []string{"example.go:19"},
+	}, {
+		Context:    "menu",
+		HasContext: true,
+		ID:         "Recent file",
+		IDPlural:   "Recent files",
+		Str:        []string{"", ""},
+		References: []string{"example.go:20"},
+	}, {
+		ID:         "multi part",
+		Str:        []string{""},
+		References: []string{"example.go:21"},
+	}, {
+		ID:         "wrapped",
+		Str:        []string{""},
+		References: []string{"example.go:23"},
+	}, {
+		ID:         "100%% done",
+		Str:        []string{""},
+		References: []string{"example.go:25"},
+	}, {
+		Context:    gettext.OrdinalContext,
+		HasContext: true,
+		ID:         "%d.",
+		IDPlural:   "%d.",
+		Str:        []string{"", ""},
+		References: []string{"example.go:26"},
+		Flags:      []string{"go-format"},
+	}, {
+		Context:    gettext.OrdinalContext + ":race",
+		HasContext: true,
+		ID:         "%d. place",
+		IDPlural:   "%d. place",
+		Str:        []string{"", ""},
+		References: []string{"example.go:27"},
+		Flags:      []string{"go-format"},
+	}}
+	if !reflect.DeepEqual(e.messages, expected) {
+		t.Errorf("unexpected messages:\n%#v", e.messages)
+	}
+	if !reflect.DeepEqual(e.warnings, []string{"example.go:24: argument 1 of Gettext is not a string literal"}) {
+		t.Errorf("unexpected warnings: %q", e.warnings)
+	}
+}
+
+func TestParseKeyword(t *testing.T) {
+	for _, test := range []struct {
+		spec     string
+		expected keyword
+		err      string
+	}{
+		{spec: "G", expected: keyword{name: "G", msgid: 1}},
+		{spec: "N:2,3", expected: keyword{name: "N", msgid: 2, msgidPlural: 3}},
+		{spec: "NP:1c,2,3", expected: keyword{name: "NP", context: 1, msgid: 2, msgidPlural: 3}},
+		{spec: "P:2,1c", expected: keyword{name: "P", context: 1, msgid: 2}},
+		{spec: "", err: "empty keyword"},
+		{spec: ":1", err: `keyword ":1" has no function name`},
+		{spec: "X:a", err: `keyword "X:a" has invalid argument "a"`},
+		{spec: "X:1,2,3", err: `keyword "X:1,2,3" has too many arguments`},
+		{spec: "X:1c", err: `keyword "X:1c" has no msgid argument`},
+	} {
+		kw, err := parseKeyword(test.spec)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseKeyword(%q): expected error %q, got %v", test.spec, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseKeyword(%q): %v", test.spec, err)
+		} else if kw != test.expected {
+			t.Errorf("parseKeyword(%q) = %+v, expected %+v", test.spec, kw, test.expected)
+		}
+	}
+}
+
+func TestIsFormatString(t *testing.T) {
+	for s, expected := range map[string]bool{
+		"plain":       false,
+		"%d items":    true,
+		"%[1]s":       true,
+		"%-5.2f":      true,
+		"100%% done":  false,
+		"100%%d done": false,
+	} {
+		if got := isFormatString(s); got != expected {
+			t.Errorf("isFormatString(%q) = %v", s, got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// keyword describes which arguments of a function call hold the
+// strings to extract, in the form used by xgettext's --keyword
+// option.  Argument positions are one-based, and zero if unused.
+type keyword struct {
+	name        string
+	context     int
+	msgid       int
+	msgidPlural int
+	// ordinal is set for gettext.Catalog's ordinal methods, whose
+	// messages are stored with gettext.OrdinalContext.
+	ordinal bool
+}
+
+// defaultKeywords matches the methods of gettext.Catalog.
+var defaultKeywords = []keyword{
+	{name: "Gettext", msgid: 1},
+	{name: "NGettext", msgid: 1, msgidPlural: 2},
+	{name: "PGettext", context: 1, msgid: 2},
+	{name: "NPGettext", context: 1, msgid: 2, msgidPlural: 3},
+	{name: "NGettextUint64", msgid: 1, msgidPlural: 2},
+	{name: "NGettextInt64", msgid: 1, msgidPlural: 2},
+	{name: "NPGettextUint64", context: 1, msgid: 2, msgidPlural: 3},
+	{name: "NPGettextInt64", context: 1, msgid: 2, msgidPlural: 3},
+	{name: "NGettextDecimal", msgid: 1, msgidPlural: 2},
+	{name: "NPGettextDecimal", context: 1, msgid: 2, msgidPlural: 3},
+	{name: "Ordinal", msgid: 1, ordinal: true},
+	{name: "POrdinal", context: 1, msgid: 2, ordinal: true},
+}
+
+// parseKeyword parses a keyword specification such as "N:1,2" or
+// "P:1c,2".  A bare function name extracts its first argument.
+func parseKeyword(spec string) (keyword, error) {
+	kw := keyword{name: spec, msgid: 1}
+	pos := strings.IndexByte(spec, ':')
+	if pos < 0 {
+		if spec == "" {
+			return kw, fmt.Errorf("empty keyword")
+		}
+		return kw, nil
+	}
+	kw = keyword{name: spec[:pos]}
+	if kw.name == "" {
+		return kw, fmt.Errorf("keyword %q has no function name", spec)
+	}
+	for _, arg := range strings.Split(spec[pos+1:], ",") {
+		isContext := strings.HasSuffix(arg, "c")
+		n, err := strconv.Atoi(strings.TrimSuffix(arg, "c"))
+		if err != nil || n < 1 {
+			return kw, fmt.Errorf("keyword %q has invalid argument %q", spec, arg)
+		}
+		switch {
+		case isContext && kw.context == 0:
+			kw.context = n
+		case !isContext && kw.msgid == 0:
+			kw.msgid = n
+		case !isContext && kw.msgidPlural == 0:
+			kw.msgidPlural = n
+		default:
+			return kw, fmt.Errorf("keyword %q has too many arguments", spec)
+		}
+	}
+	if kw.msgid == 0 {
+		return kw, fmt.Errorf("keyword %q has no msgid argument", spec)
+	}
+	return kw, nil
+}
+
+// keywordList collects the --keyword options given on the command
+// line.
+type keywordList []keyword
+
+func (l *keywordList) String() string {
+	names := make([]string, len(*l))
+	for i, kw := range *l {
+		names[i] = kw.name
+	}
+	return strings.Join(names, ",")
+}
+
+func (l *keywordList) Set(spec string) error {
+	kw, err := parseKeyword(spec)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, kw)
+	return nil
+}
@@ -0,0 +1,194 @@
+// Command xgettext-go extracts translatable strings from Go source
+// into a po template.
+//
+// By default, calls to the lookup methods of gettext.Catalog are
+// recognised: Gettext, NGettext, PGettext and NPGettext, their Uint64,
+// Int64 and Decimal count variants, and Ordinal and POrdinal, whose
+// messages are extracted with the context used for ordinal messages.
+// Other functions can be added with the --keyword option, using the
+// same syntax as GNU xgettext.  Comments preceding a call that contain
+// the comment tag (TRANSLATORS: by default) are copied to the
+// template.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/go-gettext"
+)
+
+var timeNow = time.Now
+
+type options struct {
+	output            string
+	keywords          keywordList
+	noDefaultKeywords bool
+	commentTag        string
+	omitHeader        bool
+	packageName       string
+	packageVersion    string
+	bugsAddress       string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	var opts options
+	flags := flag.NewFlagSet("xgettext-go", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: xgettext-go [OPTION] [FILE|DIR]...\n")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&opts.output, "o", "messages.pot", "write output to `file`, or standard output if -")
+	flags.StringVar(&opts.output, "output", "messages.pot", "write output to `file`, or standard output if -")
+	flags.Var(&opts.keywords, "k", "additional keyword `spec` such as name:1c,2,3")
+	flags.Var(&opts.keywords, "keyword", "additional keyword `spec` such as name:1c,2,3")
+	flags.BoolVar(&opts.noDefaultKeywords, "no-default-keywords", false, "do not recognise the gettext.Catalog methods")
+	flags.StringVar(&opts.commentTag, "c", "TRANSLATORS:", "copy comments containing `tag` to the output")
+	flags.StringVar(&opts.commentTag, "add-comments", "TRANSLATORS:", "copy comments containing `tag` to the output")
+	flags.BoolVar(&opts.omitHeader, "omit-header", false, "do not write a header entry")
+	flags.StringVar(&opts.packageName, "package-name", "PACKAGE", "package name for the header")
+	flags.StringVar(&opts.packageVersion, "package-version", "VERSION", "package version for the header")
+	flags.StringVar(&opts.bugsAddress, "msgid-bugs-address", "", "report address for msgid bugs")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "xgettext-go: no input file given")
+		return 2
+	}
+
+	var keywords []keyword
+	if !opts.noDefaultKeywords {
+		keywords = append(keywords, defaultKeywords...)
+	}
+	keywords = append(keywords, opts.keywords...)
+	e := newExtractor(keywords, opts.commentTag)
+
+	files, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "xgettext-go: %v\n", err)
+		return 1
+	}
+	for _, filename := range files {
+		if err := e.extractFile(filename, nil); err != nil {
+			fmt.Fprintf(stderr, "xgettext-go: %v\n", err)
+			return 1
+		}
+	}
+	for _, warning := range e.warnings {
+		fmt.Fprintf(stderr, "xgettext-go: warning: %s\n", warning)
+	}
+
+	messages := e.messages
+	if !opts.omitHeader {
+		messages = append([]gettext.Message{header(&opts, hasPlurals(messages))}, messages...)
+	}
+	var buf bytes.Buffer
+	if err := gettext.WritePO(&buf, messages); err != nil {
+		fmt.Fprintf(stderr, "xgettext-go: %v\n", err)
+		return 1
+	}
+	if opts.output == "-" {
+		_, err = stdout.Write(buf.Bytes())
+	} else {
+		err = ioutil.WriteFile(opts.output, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "xgettext-go: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// sourceFiles expands the command line arguments into a list of Go
+// source files.  Directories are searched recursively, skipping test
+// files, testdata and vendor directories.
+func sourceFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		var dirFiles []string
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			name := info.Name()
+			if info.IsDir() {
+				if path != arg && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+				dirFiles = append(dirFiles, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(dirFiles)
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+func hasPlurals(messages []gettext.Message) bool {
+	for _, msg := range messages {
+		if msg.IDPlural != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// header returns the template header entry, in the same form as GNU
+// xgettext.
+func header(opts *options, plurals bool) gettext.Message {
+	fields := []string{
+		"Project-Id-Version: " + opts.packageName + " " + opts.packageVersion,
+		"Report-Msgid-Bugs-To: " + opts.bugsAddress,
+		"POT-Creation-Date: " + timeNow().Format("2006-01-02 15:04-0700"),
+		"PO-Revision-Date: YEAR-MO-DA HO:MI+ZONE",
+		"Last-Translator: FULL NAME <EMAIL@ADDRESS>",
+		"Language-Team: LANGUAGE <LL@li.org>",
+		"Language: ",
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=CHARSET",
+		"Content-Transfer-Encoding: 8bit",
+	}
+	if plurals {
+		fields = append(fields, "Plural-Forms: nplurals=INTEGER; plural=EXPRESSION;")
+	}
+	return gettext.Message{
+		Str: []string{strings.Join(fields, "\n") + "\n"},
+		Comments: []string{
+			"SOME DESCRIPTIVE TITLE.",
+			"Copyright (C) YEAR THE PACKAGE'S COPYRIGHT HOLDER",
+			"This file is distributed under the same license as the " + opts.packageName + " package.",
+			"FIRST AUTHOR <EMAIL@ADDRESS>, YEAR.",
+			"",
+		},
+		Flags: []string{"fuzzy"},
+	}
+}
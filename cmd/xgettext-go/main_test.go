@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, filename, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteTemplate(t *testing.T) {
+	oldTimeNow := timeNow
+	timeNow = func() time.Time {
+		return time.Date(2020, 4, 1, 12, 30, 0, 0, time.UTC)
+	}
+	defer func() { timeNow = oldTimeNow }()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.go"), `package a
+
+func f(c Catalog, n uint32) {
+	// TRANSLATORS: a greeting
+	c.Gettext("Hello")
+	c.NGettext("%d apple", "%d apples", n)
+}
+`)
+	writeFile(t, filepath.Join(dir, "a_test.go"), `package a
+
+func g(c Catalog) { c.Gettext("test only") }
+`)
+	writeFile(t, filepath.Join(dir, "testdata", "b.go"), `package b
+
+func g(c Catalog) { c.Gettext("testdata only") }
+`)
+	writeFile(t, filepath.Join(dir, "sub", "c.go"), `package c
+
+func g(c Catalog) { T("custom keyword") }
+`)
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"-o", "-", "--package-name=example", "-k", "T", dir}, &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	slashDir := filepath.ToSlash(dir)
+	expected := `# SOME DESCRIPTIVE TITLE.
+# Copyright (C) YEAR THE PACKAGE'S COPYRIGHT HOLDER
+# This file is distributed under the same license as the example package.
+# FIRST AUTHOR <EMAIL@ADDRESS>, YEAR.
+#
+#, fuzzy
+msgid ""
+msgstr ""
+"Project-Id-Version: example VERSION\n"
+"Report-Msgid-Bugs-To: \n"
+"POT-Creation-Date: 2020-04-01 12:30+0000\n"
+"PO-Revision-Date: YEAR-MO-DA HO:MI+ZONE\n"
+"Last-Translator: FULL NAME <EMAIL@ADDRESS>\n"
+"Language-Team: LANGUAGE <LL@li.org>\n"
+"Language: \n"
+"MIME-Version: 1.0\n"
+"Content-Type: text/plain; charset=CHARSET\n"
+"Content-Transfer-Encoding: 8bit\n"
+"Plural-Forms: nplurals=INTEGER; plural=EXPRESSION;\n"
+
+#. TRANSLATORS: a greeting
+#: ` + slashDir + `/a.go:5
+msgid "Hello"
+msgstr ""
+
+#: ` + slashDir + `/a.go:6
+#, go-format
+msgid "%d apple"
+msgid_plural "%d apples"
+msgstr[0] ""
+msgstr[1] ""
+
+#: ` + slashDir + `/sub/c.go:3
+msgid "custom keyword"
+msgstr ""
+`
+	if stdout.String() != expected {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestOmitHeader(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "a.go")
+	writeFile(t, filename, `package a
+
+func f(c Catalog) { c.Gettext("Hello") }
+`)
+	output := filepath.Join(dir, "out.pot")
+	var stderr bytes.Buffer
+	status := run([]string{"--omit-header", "--no-default-keywords", "-k", "Gettext:1", "-o", output, filename}, ioutil.Discard, &stderr)
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	got, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "#: " + filepath.ToSlash(filename) + ":3\nmsgid \"Hello\"\nmsgstr \"\"\n"
+	if string(got) != expected {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
+
+func TestBadArguments(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-k", "X:0", "a.go"},
+	} {
+		if status := run(args, ioutil.Discard, ioutil.Discard); status != 2 {
+			t.Errorf("%v: expected exit status 2, got %d", args, status)
+		}
+	}
+	if status := run([]string{"does-not-exist.go"}, ioutil.Discard, ioutil.Discard); status != 1 {
+		t.Errorf("expected exit status 1 for missing file, got %d", status)
+	}
+}
@@ -0,0 +1,139 @@
+package gettext
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const decimalRuPO = `msgid ""
+msgstr ""
+"Language: ru\n"
+"Plural-Forms: nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n"
+
+msgid "%s hour"
+msgid_plural "%s hours"
+msgstr[0] "%s час"
+msgstr[1] "%s часа"
+msgstr[2] "%s часов"
+
+msgctxt "duration"
+msgid "%s day"
+msgid_plural "%s days"
+msgstr[0] "%s день"
+msgstr[1] "%s дня"
+msgstr[2] "%s дней"
+`
+
+// parsePOAndMO returns the catalog parsed from a po file, and from
+// the mo file compiled from it.
+func parsePOAndMO(t *testing.T, data string) []Catalog {
+	po, err := ParsePO(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := ReadPO(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := (&MOEncoder{}).Encode(&buf, messages); err != nil {
+		t.Fatal(err)
+	}
+	mo, err := ParseMOData(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []Catalog{po, mo}
+}
+
+func TestNGettextDecimal(t *testing.T) {
+	for _, catalog := range parsePOAndMO(t, decimalRuPO) {
+		for n, expected := range map[string]string{
+			"1":    "%s час",
+			"21":   "%s час",
+			"2":    "%s часа",
+			"5":    "%s часов",
+			"11":   "%s часов",
+			"1.5":  "%s часа",
+			"1.0":  "%s часа",
+			"0.5":  "%s часа",
+			"21.0": "%s часа",
+		} {
+			assert_equal(t, catalog.NGettextDecimal("%s hour", "%s hours", n), expected)
+		}
+		assert_equal(t, catalog.NPGettextDecimal("duration", "%s day", "%s days", "2.5"), "%s дня")
+		assert_equal(t, catalog.NPGettextDecimal("duration", "%s day", "%s days", "5"), "%s дней")
+
+		// Untranslated messages fall back to the original
+		assert_equal(t, catalog.NGettextDecimal("%s minute", "%s minutes", "1"), "%s minute")
+		assert_equal(t, catalog.NGettextDecimal("%s minute", "%s minutes", "1.0"), "%s minutes")
+		assert_equal(t, catalog.NGettextDecimal("%s minute", "%s minutes", "0.5"), "%s minutes")
+		assert_equal(t, catalog.NGettextDecimal("%s minute", "%s minutes", "1.x"), "%s minutes")
+		assert_equal(t, catalog.NGettextDecimal("%s hour", "%s hours", ""), "%s hours")
+	}
+}
+
+func TestNGettextDecimalEnglish(t *testing.T) {
+	data := `msgid ""
+msgstr ""
+"Language: en_GB\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+msgid "%s mile"
+msgid_plural "%s miles"
+msgstr[0] "%s mile (GB)"
+msgstr[1] "%s miles (GB)"
+`
+	for _, catalog := range parsePOAndMO(t, data) {
+		assert_equal(t, catalog.NGettextDecimal("%s mile", "%s miles", "1"), "%s mile (GB)")
+		assert_equal(t, catalog.NGettextDecimal("%s mile", "%s miles", "1.0"), "%s miles (GB)")
+		assert_equal(t, catalog.NGettextDecimal("%s mile", "%s miles", "0.5"), "%s miles (GB)")
+	}
+}
+
+func TestNGettextDecimalIntegerFallback(t *testing.T) {
+	for _, test := range []struct {
+		language string
+		n        string
+		expected string
+	}{
+		// Portuguese CLDR rules put 0 in "one", disagreeing
+		// with the Plural-Forms expression
+		{"pt", "1.5", "%s hora"},
+		{"pt", "0.5", "%s horas"},
+		// No CLDR rules are known for the language
+		{"xx", "1.5", "%s hora"},
+		{"xx", "2.5", "%s horas"},
+	} {
+		data := `msgid ""
+msgstr ""
+"Language: ` + test.language + `\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+msgid "%s hour"
+msgid_plural "%s hours"
+msgstr[0] "%s hora"
+msgstr[1] "%s horas"
+`
+		for _, catalog := range parsePOAndMO(t, data) {
+			assert_equal(t, catalog.NGettextDecimal("%s hour", "%s hours", test.n), test.expected)
+		}
+	}
+}
+
+func TestDecimalIndex(t *testing.T) {
+	var catalog catalogInfo
+	catalog.useLanguageRule("ru")
+	if catalog.cardinals == nil {
+		t.Fatal("expected Russian CLDR rules to agree with the standard plural forms")
+	}
+	catalog = catalogInfo{}
+	if err := catalog.readPluralForms("nplurals=2; plural=n != 1;"); err != nil {
+		t.Fatal(err)
+	}
+	catalog.useLanguageRule("pt")
+	if catalog.cardinals != nil {
+		t.Error("expected Portuguese CLDR rules to be rejected")
+	}
+}
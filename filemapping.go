@@ -5,12 +5,31 @@ import (
 	"io/ioutil"
 	"os"
 	"runtime"
+	"sync/atomic"
 )
 
 type fileMapping struct {
 	data []byte
 
 	isMapped bool
+	// refs counts the TextDomain caches and Catalogs using the
+	// mapping.
+	refs atomic.Int32
+}
+
+// acquire records a new user of the mapping.
+func (m *fileMapping) acquire() {
+	m.refs.Add(1)
+}
+
+// release drops a reference to the mapping acquired with acquire,
+// closing it once there are no other users.  Mappings that are never
+// released are closed when garbage collected.
+func (m *fileMapping) release() error {
+	if m.refs.Add(-1) != 0 {
+		return nil
+	}
+	return m.Close()
 }
 
 func (m *fileMapping) Close() error {
@@ -18,7 +37,10 @@ func (m *fileMapping) Close() error {
 	if !m.isMapped {
 		return nil
 	}
-	return m.closeMapping()
+	err := m.closeMapping()
+	m.isMapped = false
+	m.data = nil
+	return err
 }
 
 func openMapping(f *os.File) (*fileMapping, error) {
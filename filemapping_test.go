@@ -46,10 +46,10 @@ func TestFileMappingFallback(t *testing.T) {
 	r, w, err := os.Pipe()
 	go func() {
 		if _, err := w.Write([]byte("Hello world!")); err != nil {
-			t.Fatal(err)
+			t.Error(err)
 		}
 		if err := w.Close(); err != nil {
-			t.Fatal(err)
+			t.Error(err)
 		}
 	}()
 
@@ -72,3 +72,29 @@ func TestFileMappingFallback(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFileMappingRefs(t *testing.T) {
+	file, err := os.Open("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	m, err := openMapping(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.acquire()
+	m.acquire()
+	if err := m.release(); err != nil {
+		t.Fatal(err)
+	}
+	if !m.isMapped {
+		t.Fatal("mapping closed while still referenced")
+	}
+	if err := m.release(); err != nil {
+		t.Fatal(err)
+	}
+	if m.isMapped || m.data != nil {
+		t.Error("mapping not closed after last reference was released")
+	}
+}
@@ -3,9 +3,13 @@
 package gettext
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"sync"
 )
 
@@ -17,16 +21,56 @@ type TextDomain struct {
 	// Name is the name of the text domain
 	Name string
 	// LocaleDir is the base directory holding translations of the
-	// domain.  If it is empty, DefaultLocaleDir will be used.
+	// domain.  If it is empty, DefaultLocaleDir will be used, or
+	// the root of FS if that is set.
 	LocaleDir string
+	// FS is the file system translations are read from.  If it
+	// is nil, the host file system is used.  This can be used to
+	// load translations embedded in the binary with embed.FS.
+	FS fs.FS
 	// PathResolver is called to determine the path of a
 	// particular locale's translations.  If it is nil then
 	// DefaultResolver will be used, which implements the standard
-	// gettext directory layout.
+	// gettext directory layout.  If no mo file exists at the
+	// resolved path, a po file of the same name is loaded instead.
 	PathResolver PathResolver
+	// VerifyHashTables enables a check that every message of a mo
+	// file can be found through its hash table when it is loaded.
+	// Catalogs whose tables fail the check are searched with
+	// binary search instead.  This protects against corrupt or
+	// malicious mo files hiding translations, at the cost of one
+	// lookup per message at load time.
+	VerifyHashTables bool
 
 	mu    sync.Mutex
-	cache map[string]*mocatalog
+	cache map[string]cacheEntry
+}
+
+// cacheEntry records the result of loading the catalog of a locale,
+// so that missing and broken catalogs are not looked up again.
+type cacheEntry struct {
+	catalog msgCatalog
+	// err is the reason the catalog could not be loaded.  It
+	// matches fs.ErrNotExist if there is no catalog.
+	err error
+}
+
+// LoadError records a catalog that could not be loaded.
+//
+// Use errors.Is(err, fs.ErrNotExist) to tell a locale with no
+// catalog apart from one whose catalog is unreadable or corrupt.
+type LoadError struct {
+	Locale string
+	Path   string
+	Err    error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("cannot load %s translations from %s: %v", e.Locale, e.Path, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
 }
 
 const DefaultLocaleDir = "/usr/share/locale"
@@ -44,44 +88,182 @@ func DefaultResolver(root string, locale string, domain string) string {
 // to limit IO to a specific time in your app, for example startup. Subsequent
 // calls to Preload or Locale using a locale given here will not do any IO.
 func (t *TextDomain) Preload(locales ...string) {
+	t.Load(locales...)
+}
+
+// Load preloads a list of locales in the same way as Preload, but
+// returns an error describing each locale that could not be loaded,
+// including those that have no catalog.  The errors are of type
+// *LoadError, combined with errors.Join.
+//
+// Failures are cached along with the loaded catalogs, so later calls
+// report the same errors without doing any IO.
+func (t *TextDomain) Load(locales ...string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var errs []error
 	for _, locale := range locales {
-		t.load(locale)
+		if _, err := t.load(locale); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
 }
 
-func (t *TextDomain) load(locale string) *mocatalog {
+// Evict removes a list of locales from the cache, so that their
+// translations are loaded again when next used.  This can be used to
+// pick up updated translations, or retry catalogs that failed to
+// load.  Memory mapped files are unmapped once every Catalog returned
+// by Locale that uses them has been closed or garbage collected.
+func (t *TextDomain) Evict(locales ...string) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	var firstErr error
+	for _, locale := range locales {
+		if err := t.evict(locale); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close evicts all locales from the cache.  The TextDomain can still
+// be used afterwards, loading translations again as needed.
+func (t *TextDomain) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var firstErr error
+	for locale := range t.cache {
+		if err := t.evict(locale); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// evict removes a locale from the cache, releasing its reference to
+// the catalog's file mapping.  The caller must hold t.mu.
+func (t *TextDomain) evict(locale string) error {
+	entry, ok := t.cache[locale]
+	if !ok {
+		return nil
+	}
+	delete(t.cache, locale)
+	if mo, ok := entry.catalog.(*mocatalog); ok {
+		return mo.m.release()
+	}
+	return nil
+}
+
+// load returns the catalog of a locale, loading it if it is not
+// cached.  The caller must hold t.mu.
+func (t *TextDomain) load(locale string) (msgCatalog, error) {
 	if t.cache == nil {
-		t.cache = make(map[string]*mocatalog)
+		t.cache = make(map[string]cacheEntry)
 	}
 
-	if catalog, ok := t.cache[locale]; ok {
-		return catalog
+	if entry, ok := t.cache[locale]; ok {
+		return entry.catalog, entry.err
 	}
 
 	localeDir := t.LocaleDir
 	if localeDir == "" {
-		localeDir = DefaultLocaleDir
+		if t.FS != nil {
+			localeDir = "."
+		} else {
+			localeDir = DefaultLocaleDir
+		}
 	}
 	resolver := t.PathResolver
 	if resolver == nil {
 		resolver = DefaultResolver
 	}
-	t.cache[locale] = nil
 	path := resolver(localeDir, locale, t.Name)
-	f, err := os.Open(path)
+	catalog, path, err := t.open(path)
 	if err != nil {
-		return nil
+		err = &LoadError{Locale: locale, Path: path, Err: err}
+		t.cache[locale] = cacheEntry{err: err}
+		return nil, err
+	}
+	// Catalogs lacking both a Plural-Forms header and a known
+	// Language header use the plural forms of the locale.
+	catalog.useLanguageRule(locale)
+	if mo, ok := catalog.(*mocatalog); ok {
+		mo.m.acquire()
+	}
+	t.cache[locale] = cacheEntry{catalog: catalog}
+	return catalog, nil
+}
+
+// open parses the catalog at path.  If there is no mo file at that
+// path, a po file with the same base name is tried instead.  The path
+// of the file that was loaded, or that failed to load, is returned.
+// If neither file exists, the mo file is reported.
+func (t *TextDomain) open(path string) (msgCatalog, string, error) {
+	mo, err := t.openMO(path)
+	if err == nil {
+		return mo, path, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) && strings.HasSuffix(path, ".mo") {
+		poPath := strings.TrimSuffix(path, ".mo") + ".po"
+		po, poErr := t.openPO(poPath)
+		if poErr == nil {
+			return po, poPath, nil
+		}
+		if !errors.Is(poErr, fs.ErrNotExist) {
+			return nil, poPath, poErr
+		}
+	}
+	return nil, path, err
+}
+
+// openFile opens path, reading it from FS if set.
+func (t *TextDomain) openFile(path string) (fs.File, error) {
+	if t.FS == nil {
+		return os.Open(path)
+	}
+	return t.FS.Open(path)
+}
+
+func (t *TextDomain) openMO(path string) (*mocatalog, error) {
+	mo, err := t.readMO(path)
+	if err != nil {
+		return nil, err
+	}
+	if t.VerifyHashTables {
+		mo.verifyHashTable()
+	}
+	return mo, nil
+}
+
+func (t *TextDomain) readMO(path string) (*mocatalog, error) {
+	f, err := t.openFile(path)
+	if err != nil {
+		return nil, err
 	}
 	defer f.Close()
-	catalog, err := parseMO(f)
+	// Files from the host file system or os.DirFS can be memory
+	// mapped
+	if osFile, ok := f.(*os.File); ok {
+		return parseMO(osFile)
+	}
+	data, err := ioutil.ReadAll(f)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	t.cache[locale] = catalog
-	return catalog
+	return parseMOData(data)
+}
+
+func (t *TextDomain) openPO(path string) (*pocatalog, error) {
+	f, err := t.openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parsePO(f)
 }
 
 // Locale returns the catalog translations for a list of locales.
@@ -89,15 +271,42 @@ func (t *TextDomain) load(locale string) *mocatalog {
 // If translations are not found in the first locale, the each
 // subsequent one is consulted until a match is found.  If no match is
 // found, the original strings are returned.
+//
+// The catalog can be closed once it is no longer needed, to release
+// the memory mapped files it uses without waiting for it to be
+// garbage collected.
 func (t *TextDomain) Locale(languages ...string) Catalog {
-	var mos []*mocatalog
+	catalog, _ := t.LoadLocale(languages...)
+	return catalog
+}
+
+// LoadLocale returns the catalog translations for a list of locales
+// in the same way as Locale, along with an error describing any
+// catalogs that exist but could not be loaded.  Locales with no
+// catalog are skipped without error, since most of the fallback
+// locales consulted are expected to be missing.
+//
+// The returned Catalog holds the translations that could be loaded
+// even if an error is returned.
+func (t *TextDomain) LoadLocale(languages ...string) (Catalog, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var catalogs []msgCatalog
+	var errs []error
 	for _, lang := range normalizeLanguages(languages) {
-		mo := t.load(lang)
-		if mo != nil {
-			mos = append(mos, mo)
+		catalog, err := t.load(lang)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
 		}
+		catalogs = append(catalogs, catalog)
 	}
-	return Catalog{mos}
+	// The Catalog holds its own references to the mappings, so
+	// that it remains usable if the locales are evicted
+	return newCatalog(catalogs), errors.Join(errs...)
 }
 
 // UserLocale returns the catalog translations for the user's Locale.
@@ -1,13 +1,23 @@
 package gettext
 
 import (
+	"embed"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path"
+	"runtime"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
+//go:embed testdata
+var testdataFS embed.FS
+
 func TestNewTranslations(t *testing.T) {
 	// The result of NewTranslations can be assigned to a variable
 	// using the deprecated Translations alias.
@@ -125,6 +135,57 @@ func TestFallbackCatalog(t *testing.T) {
 	assert_equal(t, cat.Gettext("greeting"), "Hello")
 }
 
+func TestEmbedFS(t *testing.T) {
+	translations := &TextDomain{Name: "messages", LocaleDir: "testdata", PathResolver: my_resolver, FS: testdataFS}
+	en := translations.Locale("en")
+	assert_equal(t, en.Gettext("greeting"), "Hello")
+	assert_equal(t,
+		fmt.Sprintf(en.NGettext("order %d beer", "order %d beers", 2), 2),
+		"2 beers please",
+	)
+	ja := translations.Locale("ja")
+	assert_equal(t, ja.Gettext("greeting"), "こんいちは")
+	de := translations.Locale("de")
+	assert_equal(t, de.Gettext("greeting"), "greeting")
+}
+
+func TestDirFS(t *testing.T) {
+	// Catalogs opened via os.DirFS are *os.File, and get mapped
+	translations := &TextDomain{Name: "messages", PathResolver: my_resolver, FS: os.DirFS("testdata")}
+	en := translations.Locale("en")
+	assert_equal(t, en.Gettext("greeting"), "Hello")
+}
+
+func TestMapFSDefaultLayout(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/es/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// With FS set, an empty LocaleDir refers to the root of the FS
+	translations := &TextDomain{Name: "messages", FS: fstest.MapFS{
+		"es/LC_MESSAGES/messages.mo": &fstest.MapFile{Data: data},
+	}}
+	es := translations.Locale("es")
+	assert_equal(t, es.PGettext("weapon", "bow"), "arco")
+}
+
+func TestPOFallback(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/ja/messages.po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// With no mo file available, the po file is used instead
+	translations := &TextDomain{Name: "messages", FS: fstest.MapFS{
+		"ja/LC_MESSAGES/messages.po": &fstest.MapFile{Data: data},
+	}}
+	ja := translations.Locale("ja")
+	assert_equal(t, ja.Gettext("greeting"), "こんいちは")
+	assert_equal(t,
+		fmt.Sprintf(ja.NGettext("order %d beer", "order %d beers", 2), 2),
+		"ビールを2杯ください",
+	)
+}
+
 func TestPreload(t *testing.T) {
 	dir, err := ioutil.TempDir("", "gogettext")
 	if err != nil {
@@ -271,3 +332,225 @@ func TestNotMoFile(t *testing.T) {
 	)
 
 }
+
+func TestLoadErrors(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapFS := fstest.MapFS{
+		"en/LC_MESSAGES/messages.mo": &fstest.MapFile{Data: data},
+		"fr/LC_MESSAGES/messages.mo": &fstest.MapFile{Data: []byte("not a mo file")},
+		"de/LC_MESSAGES/messages.po": &fstest.MapFile{Data: []byte(`msgid "greeting`)},
+	}
+	translations := &TextDomain{Name: "messages", FS: mapFS}
+
+	err = translations.Load("en", "fr", "de", "ja")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	var loadErr *LoadError
+	for _, test := range []struct {
+		locale   string
+		path     string
+		notFound bool
+	}{
+		{"fr", "fr/LC_MESSAGES/messages.mo", false},
+		{"de", "de/LC_MESSAGES/messages.po", false},
+		{"ja", "ja/LC_MESSAGES/messages.mo", true},
+	} {
+		err := translations.Load(test.locale)
+		if !errors.As(err, &loadErr) {
+			t.Fatalf("expected a LoadError for %s, got %v", test.locale, err)
+		}
+		assert_equal(t, loadErr.Locale, test.locale)
+		assert_equal(t, loadErr.Path, test.path)
+		assertDeepEqual(t, errors.Is(err, fs.ErrNotExist), test.notFound)
+	}
+	if err := translations.Load("en"); err != nil {
+		t.Error(err)
+	}
+
+	// Missing catalogs are not reported by LoadLocale, but broken
+	// ones are, along with the catalogs that could be loaded
+	catalog, err := translations.LoadLocale("ja", "fr", "en")
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("expected a LoadError, got %v", err)
+	}
+	assert_equal(t, loadErr.Locale, "fr")
+	assert_equal(t, catalog.Gettext("greeting"), "Hello")
+	catalog, err = translations.LoadLocale("ja", "en")
+	if err != nil {
+		t.Error(err)
+	}
+	assert_equal(t, catalog.Gettext("greeting"), "Hello")
+
+	// Failures are cached
+	mapFS["fr/LC_MESSAGES/messages.mo"] = &fstest.MapFile{Data: data}
+	mapFS["ja/LC_MESSAGES/messages.mo"] = &fstest.MapFile{Data: data}
+	if err := translations.Load("fr"); err == nil {
+		t.Error("expected the cached error for fr")
+	}
+	if err := translations.Load("ja"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the cached error for ja, got %v", err)
+	}
+}
+
+// catalogMapping returns the file mapping of a Catalog's first catalog.
+func catalogMapping(t *testing.T, catalog Catalog) *fileMapping {
+	if len(catalog.catalogs) == 0 {
+		t.Fatal("no catalog loaded")
+	}
+	mo, ok := catalog.catalogs[0].(*mocatalog)
+	if !ok {
+		t.Fatalf("unexpected catalog type %T", catalog.catalogs[0])
+	}
+	return mo.m
+}
+
+func TestEvict(t *testing.T) {
+	translations := &TextDomain{Name: "messages", LocaleDir: "testdata/", PathResolver: my_resolver}
+	en := translations.Locale("en")
+	m := catalogMapping(t, en)
+	if !m.isMapped {
+		t.Skip("catalog is not memory mapped")
+	}
+	assertDeepEqual(t, m.refs.Load(), int32(2))
+
+	// The catalog keeps the mapping alive after eviction
+	if err := translations.Evict("en", "fr"); err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, m.refs.Load(), int32(1))
+	assert_equal(t, en.Gettext("greeting"), "Hello")
+
+	// The locale is loaded again when next used
+	en2 := translations.Locale("en")
+	if catalogMapping(t, en2) == m {
+		t.Error("evicted catalog was not reloaded")
+	}
+	assert_equal(t, en2.Gettext("greeting"), "Hello")
+
+	copied := en
+	if err := en.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if m.isMapped {
+		t.Error("mapping not closed with its last catalog")
+	}
+	// Closed catalogs and their copies return original messages
+	assert_equal(t, en.Gettext("greeting"), "greeting")
+	assert_equal(t, copied.Gettext("greeting"), "greeting")
+	// Closing again has no effect
+	if err := copied.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, en2.Gettext("greeting"), "Hello")
+}
+
+func TestTextDomainClose(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(path.Join(dir, "en", "LC_MESSAGES"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	mo := path.Join(dir, "en", "LC_MESSAGES", "messages.mo")
+	copyFile := func(src string) {
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Replace the file rather than overwriting the mapped data
+		if err := ioutil.WriteFile(mo+".new", data, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(mo+".new", mo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copyFile("testdata/en/messages.mo")
+
+	translations := &TextDomain{Name: "messages", LocaleDir: dir}
+	en := translations.Locale("en")
+	assert_equal(t, en.Gettext("greeting"), "Hello")
+	m := catalogMapping(t, en)
+
+	copyFile("testdata/ja/messages.mo")
+	cached := translations.Locale("en")
+	assert_equal(t, cached.Gettext("greeting"), "Hello")
+	if err := translations.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, translations.Locale("en").Gettext("greeting"), "こんいちは")
+	// The catalogs returned before Close are still usable
+	assert_equal(t, en.Gettext("greeting"), "Hello")
+	assertDeepEqual(t, m.refs.Load(), int32(2))
+	if err := en.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cached.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, m.refs.Load(), int32(0))
+}
+
+func TestCatalogGarbageCollected(t *testing.T) {
+	translations := &TextDomain{Name: "messages", LocaleDir: "testdata/", PathResolver: my_resolver}
+	// The catalog is dropped without being closed
+	m := catalogMapping(t, translations.Locale("en"))
+	if err := translations.Evict("en"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && m.refs.Load() != 0; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	assertDeepEqual(t, m.refs.Load(), int32(0))
+}
+
+func TestCatalogCloseConcurrent(t *testing.T) {
+	translations := &TextDomain{Name: "messages", LocaleDir: "testdata/", PathResolver: my_resolver}
+	catalog := translations.Locale("en")
+	if err := translations.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(copied Catalog) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if msgstr := copied.Gettext("greeting"); msgstr != "Hello" && msgstr != "greeting" {
+					t.Errorf("unexpected translation %q", msgstr)
+					return
+				}
+			}
+		}(catalog)
+	}
+	// Closing waits for lookups in progress through the copies
+	if err := catalog.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	assert_equal(t, catalog.Gettext("greeting"), "greeting")
+}
+
+func TestParseMOClose(t *testing.T) {
+	file, err := os.Open("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	catalog, err := ParseMO(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := catalogMapping(t, catalog)
+	assertDeepEqual(t, m.refs.Load(), int32(1))
+	if err := catalog.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if m.isMapped {
+		t.Error("mapping not closed with its catalog")
+	}
+}
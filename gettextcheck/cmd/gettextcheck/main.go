@@ -0,0 +1,15 @@
+// Command gettextcheck runs the gettextcheck analyzer.
+//
+// It can be run directly, or through go vet:
+//
+//	go vet -vettool=$(which gettextcheck) ./...
+package main
+
+import (
+	"github.com/snapcore/go-gettext/gettextcheck"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(gettextcheck.Analyzer)
+}
@@ -0,0 +1,264 @@
+package gettextcheck
+
+import (
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// verb is a directive of a format string, and the argument it
+// consumes.
+type verb struct {
+	arg  int
+	verb string
+}
+
+// parseFormat returns the directives of a printf format string,
+// following the rules of package fmt.  Widths and precisions given by
+// '*' are returned as a "*" verb.
+func parseFormat(format string) []verb {
+	var verbs []verb
+	arg := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		// Flags
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		i, arg = argIndex(format, i, arg)
+		// Width
+		if i < len(format) && format[i] == '*' {
+			verbs = append(verbs, verb{arg: arg, verb: "*"})
+			arg++
+			i++
+		} else {
+			for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+				i++
+			}
+		}
+		// Precision
+		if i < len(format) && format[i] == '.' {
+			i++
+			i, arg = argIndex(format, i, arg)
+			if i < len(format) && format[i] == '*' {
+				verbs = append(verbs, verb{arg: arg, verb: "*"})
+				arg++
+				i++
+			} else {
+				for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+					i++
+				}
+			}
+		}
+		i, arg = argIndex(format, i, arg)
+		if i >= len(format) {
+			break
+		}
+		r, size := utf8.DecodeRuneInString(format[i:])
+		i += size - 1
+		if r == '%' {
+			continue
+		}
+		verbs = append(verbs, verb{arg: arg, verb: "%" + string(r)})
+		arg++
+	}
+	return verbs
+}
+
+// argIndex parses an explicit argument index such as "[2]" at
+// format[i:], returning the position following it and the zero-based
+// argument index.
+func argIndex(format string, i, arg int) (int, int) {
+	if i >= len(format) || format[i] != '[' {
+		return i, arg
+	}
+	end := strings.IndexByte(format[i:], ']')
+	if end < 0 {
+		return i, arg
+	}
+	n, err := strconv.Atoi(format[i+1 : i+end])
+	if err != nil || n < 1 {
+		return i, arg
+	}
+	return i + end + 1, n - 1
+}
+
+// formatVerbs returns the verbs of format ordered by the argument
+// they apply to.
+func formatVerbs(format string) []string {
+	verbs := parseFormat(format)
+	sort.SliceStable(verbs, func(i, j int) bool {
+		return verbs[i].arg < verbs[j].arg
+	})
+	result := make([]string, len(verbs))
+	for i, v := range verbs {
+		result[i] = v.verb
+	}
+	return result
+}
+
+// formatArgCount returns the number of arguments consumed by format.
+func formatArgCount(format string) int {
+	count := 0
+	for _, v := range parseFormat(format) {
+		if v.arg+1 > count {
+			count = v.arg + 1
+		}
+	}
+	return count
+}
+
+// argKind is a bit set of the kinds of argument accepted by a verb.
+type argKind int
+
+const (
+	argBool argKind = 1 << iota
+	argInt
+	argRune
+	argFloat
+	argComplex
+	argString
+	argPointer
+	argError
+	argAny = -1
+)
+
+// verbArgKinds holds the kinds of argument accepted by each verb, as
+// in vet's printf check.  Widths and precisions given by '*' must be
+// integers.
+var verbArgKinds = map[string]argKind{
+	"*":  argInt,
+	"%b": argInt | argFloat | argComplex | argPointer,
+	"%c": argRune | argInt,
+	"%d": argInt | argPointer,
+	"%e": argFloat | argComplex,
+	"%E": argFloat | argComplex,
+	"%f": argFloat | argComplex,
+	"%F": argFloat | argComplex,
+	"%g": argFloat | argComplex,
+	"%G": argFloat | argComplex,
+	"%o": argInt | argPointer,
+	"%O": argInt | argPointer,
+	"%p": argPointer,
+	"%q": argRune | argInt | argString,
+	"%s": argString,
+	"%t": argBool,
+	"%T": argAny,
+	"%U": argRune | argInt,
+	"%v": argAny,
+	"%w": argError,
+	"%x": argRune | argInt | argFloat | argComplex | argString | argPointer,
+	"%X": argRune | argInt | argFloat | argComplex | argString | argPointer,
+}
+
+// matchArgType returns true if an argument of type typ can be
+// formatted by a verb accepting the given kinds of argument.  Types
+// that format themselves, interfaces and type parameters match any
+// verb, and the errors and Stringers match the verbs for strings.
+// The elements of composite types are checked recursively, as fmt
+// formats them one by one.
+func matchArgType(kinds argKind, typ types.Type) bool {
+	return matchArgTypeSeen(kinds, typ, make(map[types.Type]bool))
+}
+
+func matchArgTypeSeen(kinds argKind, typ types.Type, seen map[types.Type]bool) bool {
+	if kinds == argAny || hasMethod(typ, "Format", 2) {
+		return true
+	}
+	if hasMethod(typ, "Error", 0) {
+		if kinds&(argString|argError) != 0 {
+			return true
+		}
+	} else if kinds == argError {
+		return false
+	}
+	if kinds&argString != 0 && hasMethod(typ, "String", 0) {
+		return true
+	}
+	if seen[typ] {
+		// A recursive type, which is checked elsewhere
+		return true
+	}
+	seen[typ] = true
+
+	switch t := typ.Underlying().(type) {
+	case *types.Interface, *types.TypeParam:
+		return true
+	case *types.Basic:
+		info := t.Info()
+		switch {
+		case t.Kind() == types.UnsafePointer || t.Kind() == types.UntypedNil:
+			return kinds&argPointer != 0
+		case info&types.IsBoolean != 0:
+			return kinds&argBool != 0
+		case info&types.IsInteger != 0:
+			return kinds&(argInt|argRune) != 0
+		case info&types.IsFloat != 0:
+			return kinds&argFloat != 0
+		case info&types.IsComplex != 0:
+			return kinds&argComplex != 0
+		case info&types.IsString != 0:
+			return kinds&argString != 0
+		}
+		return false
+	case *types.Slice:
+		// Only %p formats a slice as a pointer
+		if kinds&argString != 0 && isByte(t.Elem()) || kinds == argPointer {
+			return true
+		}
+		return matchArgTypeSeen(kinds, t.Elem(), seen)
+	case *types.Array:
+		if kinds&argString != 0 && isByte(t.Elem()) {
+			return true
+		}
+		return matchArgTypeSeen(kinds, t.Elem(), seen)
+	case *types.Map:
+		if kinds == argPointer {
+			return true
+		}
+		return matchArgTypeSeen(kinds, t.Key(), seen) && matchArgTypeSeen(kinds, t.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if !matchArgTypeSeen(kinds, t.Field(i).Type(), seen) {
+				return false
+			}
+		}
+		return true
+	case *types.Pointer:
+		if kinds&argPointer != 0 {
+			return true
+		}
+		// fmt formats pointers to composite values as &value
+		switch t.Elem().Underlying().(type) {
+		case *types.Struct, *types.Array, *types.Slice, *types.Map:
+			return matchArgTypeSeen(kinds, t.Elem(), seen)
+		}
+		return false
+	case *types.Chan, *types.Signature:
+		return kinds&argPointer != 0
+	}
+	return false
+}
+
+// hasMethod returns true if the method set of typ has a method of the
+// given name and number of parameters.
+func hasMethod(typ types.Type, name string, params int) bool {
+	methods := types.NewMethodSet(typ)
+	for i := 0; i < methods.Len(); i++ {
+		fn := methods.At(i).Obj()
+		if fn.Name() == name {
+			return fn.Type().(*types.Signature).Params().Len() == params
+		}
+	}
+	return false
+}
+
+func isByte(typ types.Type) bool {
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Kind() == types.Byte
+}
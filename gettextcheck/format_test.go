@@ -0,0 +1,34 @@
+package gettextcheck
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatVerbs(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		verbs  string
+		args   int
+	}{
+		{"plain", "", 0},
+		{"100%% done", "", 0},
+		{"%d of %s", "%d %s", 2},
+		{"%[2]s %[1]d", "%d %s", 2},
+		{"%[2]d", "%d", 2},
+		{"%-5.2f %+q", "%f %q", 2},
+		{"%*d", "* %d", 2},
+		{"%.*f", "* %f", 2},
+		{"%[3]*.[2]*[1]f", "%f * *", 3},
+		{"%v %é", "%v %é", 2},
+		{"trailing %", "", 0},
+	} {
+		verbs := strings.Join(formatVerbs(test.format), " ")
+		if verbs != test.verbs {
+			t.Errorf("formatVerbs(%q) = %q, expected %q", test.format, verbs, test.verbs)
+		}
+		if args := formatArgCount(test.format); args != test.args {
+			t.Errorf("formatArgCount(%q) = %d, expected %d", test.format, args, test.args)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Package gettextcheck defines an Analyzer that reports misuse of the
+// gettext.Catalog translation methods.
+package gettextcheck
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const Doc = `check calls to gettext.Catalog translation methods
+
+The gettextcheck analyzer reports:
+
+- message IDs and contexts that are not constant strings, as they
+  cannot be extracted for translation;
+- NGettext and NPGettext calls whose singular and plural message IDs
+  use different format verbs;
+- PGettext, NPGettext and POrdinal calls with an empty context;
+- translated messages used as printf format strings, where the verbs
+  of the message do not match the number of arguments of the call, or
+  the type of the argument they format.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "gettextcheck",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+const gettextPath = "github.com/snapcore/go-gettext"
+
+// catalogMethod records which arguments of a Catalog method hold the
+// context and message IDs.  Unused arguments are -1.
+type catalogMethod struct {
+	context     int
+	msgid       int
+	msgidPlural int
+}
+
+var catalogMethods = map[string]catalogMethod{
+	"Gettext":   {context: -1, msgid: 0, msgidPlural: -1},
+	"NGettext":  {context: -1, msgid: 0, msgidPlural: 1},
+	"PGettext":  {context: 0, msgid: 1, msgidPlural: -1},
+	"NPGettext": {context: 0, msgid: 1, msgidPlural: 2},
+	"Ordinal":   {context: -1, msgid: 0, msgidPlural: -1},
+	"POrdinal":  {context: 0, msgid: 1, msgidPlural: -1},
+
+	"NGettextUint64":  {context: -1, msgid: 0, msgidPlural: 1},
+	"NGettextInt64":   {context: -1, msgid: 0, msgidPlural: 1},
+	"NPGettextUint64": {context: 0, msgid: 1, msgidPlural: 2},
+	"NPGettextInt64":  {context: 0, msgid: 1, msgidPlural: 2},
+
+	"NGettextDecimal":  {context: -1, msgid: 0, msgidPlural: 1},
+	"NPGettextDecimal": {context: 0, msgid: 1, msgidPlural: 2},
+}
+
+// printfFuncs maps printf style functions to the index of their
+// format argument.
+var printfFuncs = map[string]int{
+	"fmt.Appendf":              1,
+	"fmt.Errorf":               0,
+	"fmt.Fprintf":              1,
+	"fmt.Printf":               0,
+	"fmt.Sprintf":              0,
+	"log.Fatalf":               0,
+	"log.Panicf":               0,
+	"log.Printf":               0,
+	"(*log.Logger).Fatalf":     0,
+	"(*log.Logger).Panicf":     0,
+	"(*log.Logger).Printf":     0,
+	"(*testing.common).Errorf": 0,
+	"(*testing.common).Fatalf": 0,
+	"(*testing.common).Logf":   0,
+	"(*testing.common).Skipf":  0,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.CallExpr)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok {
+			return
+		}
+		if name, method, ok := asCatalogMethod(fn); ok {
+			checkCatalogCall(pass, call, name, method)
+		} else if formatIndex, ok := printfFuncs[fn.FullName()]; ok {
+			checkPrintfCall(pass, call, formatIndex)
+		}
+	})
+	return nil, nil
+}
+
+// asCatalogMethod returns the description of fn if it is one of the
+// translation methods of gettext.Catalog.
+func asCatalogMethod(fn *types.Func) (string, catalogMethod, bool) {
+	method, ok := catalogMethods[fn.Name()]
+	if !ok {
+		return "", method, false
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() == nil {
+		return "", method, false
+	}
+	recv := sig.Recv().Type()
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	named, ok := recv.(*types.Named)
+	if !ok {
+		return "", method, false
+	}
+	obj := named.Obj()
+	if obj.Name() != "Catalog" || obj.Pkg() == nil || obj.Pkg().Path() != gettextPath {
+		return "", method, false
+	}
+	return fn.Name(), method, true
+}
+
+// stringArg returns the constant value of a string argument.
+func stringArg(pass *analysis.Pass, arg ast.Expr) (string, bool) {
+	tv, ok := pass.TypesInfo.Types[arg]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+func checkCatalogCall(pass *analysis.Pass, call *ast.CallExpr, name string, method catalogMethod) {
+	if method.msgidPlural >= len(call.Args) {
+		return
+	}
+	for _, arg := range []struct {
+		index int
+		what  string
+	}{
+		{method.context, "msgctxt"},
+		{method.msgid, "msgid"},
+		{method.msgidPlural, "msgidPlural"},
+	} {
+		if arg.index < 0 {
+			continue
+		}
+		if _, ok := stringArg(pass, call.Args[arg.index]); !ok {
+			pass.Reportf(call.Args[arg.index].Pos(), "non-constant %s passed to %s cannot be extracted for translation", arg.what, name)
+		}
+	}
+
+	if method.context >= 0 {
+		if msgctxt, ok := stringArg(pass, call.Args[method.context]); ok && msgctxt == "" {
+			pass.Reportf(call.Args[method.context].Pos(), "empty msgctxt passed to %s", name)
+		}
+	}
+
+	if method.msgidPlural >= 0 {
+		msgid, ok1 := stringArg(pass, call.Args[method.msgid])
+		msgidPlural, ok2 := stringArg(pass, call.Args[method.msgidPlural])
+		if ok1 && ok2 {
+			verbs := formatVerbs(msgid)
+			pluralVerbs := formatVerbs(msgidPlural)
+			if strings.Join(verbs, " ") != strings.Join(pluralVerbs, " ") {
+				pass.Reportf(call.Args[method.msgidPlural].Pos(), "%s msgid and msgidPlural use different format verbs: %s vs %s", name, describeVerbs(verbs), describeVerbs(pluralVerbs))
+			}
+		}
+	}
+}
+
+func describeVerbs(verbs []string) string {
+	if len(verbs) == 0 {
+		return "none"
+	}
+	return strings.Join(verbs, " ")
+}
+
+// checkPrintfCall checks printf style calls whose format string is a
+// translated message, checking the verbs of the message against the
+// number and types of the arguments of the call.
+func checkPrintfCall(pass *analysis.Pass, call *ast.CallExpr, formatIndex int) {
+	if formatIndex >= len(call.Args) || call.Ellipsis.IsValid() {
+		return
+	}
+	format, ok := ast.Unparen(call.Args[formatIndex]).(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	fn, ok := typeutil.Callee(pass.TypesInfo, format).(*types.Func)
+	if !ok {
+		return
+	}
+	name, method, ok := asCatalogMethod(fn)
+	if !ok || method.msgidPlural >= len(format.Args) {
+		return
+	}
+
+	numArgs := len(call.Args) - formatIndex - 1
+	for _, index := range []int{method.msgid, method.msgidPlural} {
+		if index < 0 {
+			continue
+		}
+		msgid, ok := stringArg(pass, format.Args[index])
+		if !ok {
+			continue
+		}
+		if want := formatArgCount(msgid); want != numArgs {
+			pass.Reportf(format.Args[index].Pos(), "message %q translated by %s is used as a format string with %d verbs, but the call has %d arguments", msgid, name, want, numArgs)
+		}
+		for _, v := range parseFormat(msgid) {
+			kinds, ok := verbArgKinds[v.verb]
+			if !ok || v.arg >= numArgs {
+				continue
+			}
+			arg := call.Args[formatIndex+1+v.arg]
+			if typ := pass.TypesInfo.TypeOf(arg); typ != nil && !matchArgType(kinds, typ) {
+				pass.Reportf(arg.Pos(), "message %q translated by %s has %s verb for arg %s of wrong type %s", msgid, name, v.verb, types.ExprString(arg), typ)
+			}
+		}
+	}
+}
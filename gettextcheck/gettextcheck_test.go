@@ -0,0 +1,12 @@
+package gettextcheck_test
+
+import (
+	"testing"
+
+	"github.com/snapcore/go-gettext/gettextcheck"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), gettextcheck.Analyzer, "a")
+}
@@ -0,0 +1,79 @@
+package a
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/snapcore/go-gettext"
+)
+
+const greeting = "Hello"
+
+type other struct{}
+
+func (other) Gettext(msgid string) string { return msgid }
+
+func calls(c gettext.Catalog, s string, n uint32) {
+	c.Gettext("Hello")
+	c.Gettext(greeting)
+	c.Gettext("Hello, " + "world")
+	c.Gettext(s)                              // want `non-constant msgid passed to Gettext cannot be extracted for translation`
+	c.PGettext(s, "Open")                     // want `non-constant msgctxt passed to PGettext`
+	c.PGettext("", "Open")                    // want `empty msgctxt passed to PGettext`
+	c.NPGettext("", "%d file", "%d files", n) // want `empty msgctxt passed to NPGettext`
+
+	c.NGettext("%d file", "%d files", n)
+	c.NGettext("%d file in %s", "%[2]s has %[1]d files", n)
+	c.NGettext("one file", "%d files", n) // want `NGettext msgid and msgidPlural use different format verbs: none vs %d`
+	c.NGettext("%d file", "%s files", n)  // want `NGettext msgid and msgidPlural use different format verbs: %d vs %s`
+	c.NPGettext("ctx", "%d file", "%d", n)
+	c.NPGettext("ctx", "%d file", s, n) // want `non-constant msgidPlural passed to NPGettext`
+	c.NGettextInt64("%d day ago", "%d days ago", -1)
+	c.NGettextInt64("%d day ago", "%s days ago", -1) // want `NGettextInt64 msgid and msgidPlural use different format verbs: %d vs %s`
+	c.Ordinal("%d.", n)
+	c.Ordinal(s, n)          // want `non-constant msgid passed to Ordinal`
+	c.POrdinal("", "%d.", n) // want `empty msgctxt passed to POrdinal`
+	c.POrdinal("race", "%d.", n)
+
+	// Methods of other types are ignored
+	var o other
+	o.Gettext(s)
+}
+
+func formats(c gettext.Catalog, n uint32, err error) {
+	fmt.Printf(c.Gettext("%d items\n"), n)
+	fmt.Printf(c.Gettext("%d items in %s\n"), n) // want `message "%d items in %s\\n" translated by Gettext is used as a format string with 2 verbs, but the call has 1 arguments`
+	fmt.Sprintf(c.Gettext("100%% done"))
+	fmt.Sprintf(c.Gettext("%[2]s %[1]s"), "a") // want `used as a format string with 2 verbs, but the call has 1 arguments`
+	fmt.Sprintf(c.Gettext("%*d"), 5, 10)
+	_ = fmt.Errorf(c.Gettext("cannot open: %v"), err)
+	_ = fmt.Errorf(c.PGettext("ctx", "cannot open")) // no format verbs, no arguments
+	fmt.Fprintf(nil, c.NGettext("%d file", "%d files", n), n)
+	fmt.Sprintf(c.Ordinal("%d.", n))                       // want `message "%d." translated by Ordinal is used as a format string with 1 verbs, but the call has 0 arguments`
+	fmt.Fprintf(nil, c.NGettext("%d file", "%d files", n)) // want `message "%d file" translated by NGettext` `message "%d files" translated by NGettext`
+	log.Printf(c.Gettext("%s"), "a", "b")                  // want `with 1 verbs, but the call has 2 arguments`
+
+	args := []interface{}{1, 2}
+	fmt.Printf(c.Gettext("%d"), args...)
+}
+
+type stringer struct{}
+
+func (stringer) String() string { return "" }
+
+func argTypes(c gettext.Catalog, n uint32, s string, b []byte, f float64, err error, v interface{}) {
+	fmt.Printf(c.Gettext("%d of %s"), n, s)
+	fmt.Printf(c.Gettext("%d items"), s)       // want `message "%d items" translated by Gettext has %d verb for arg s of wrong type string`
+	fmt.Printf(c.Gettext("%[2]s %[1]d"), n, f) // want `has %s verb for arg f of wrong type float64`
+	fmt.Printf(c.Gettext("%s %q %x"), b, stringer{}, s)
+	fmt.Printf(c.Gettext("%s"), err)
+	fmt.Printf(c.Gettext("%.2f%%"), f)
+	fmt.Printf(c.Gettext("%*d"), f, n) // want `has \* verb for arg f of wrong type float64`
+	fmt.Printf(c.Gettext("%t"), v)
+	fmt.Printf(c.Gettext("%v %T"), n, s)
+	fmt.Printf(c.Gettext("%d"), []int{1, 2})
+	fmt.Printf(c.Gettext("%d"), []string{"a"}) // want `has %d verb for arg .* of wrong type \[\]string`
+	_ = fmt.Errorf(c.Gettext("cannot open: %w"), err)
+	_ = fmt.Errorf(c.Gettext("cannot open: %w"), s)     // want `has %w verb for arg s of wrong type string`
+	fmt.Printf(c.NGettext("%d file", "%d files", n), s) // want `message "%d file" translated by NGettext has %d verb` `message "%d files" translated by NGettext has %d verb`
+}
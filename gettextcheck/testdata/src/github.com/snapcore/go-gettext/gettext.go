@@ -0,0 +1,14 @@
+// Package gettext is a stub of the real package for analyzer tests.
+package gettext
+
+type Catalog struct{}
+
+func (c Catalog) Gettext(msgid string) string                         { return msgid }
+func (c Catalog) NGettext(msgid, msgidPlural string, n uint32) string { return msgid }
+func (c Catalog) PGettext(msgctxt, msgid string) string               { return msgid }
+func (c Catalog) NPGettext(msgctxt, msgid, msgidPlural string, n uint32) string {
+	return msgid
+}
+func (c Catalog) NGettextInt64(msgid, msgidPlural string, n int64) string { return msgid }
+func (c Catalog) Ordinal(msgid string, n uint32) string                   { return msgid }
+func (c Catalog) POrdinal(msgctxt, msgid string, n uint32) string         { return msgid }
@@ -0,0 +1,176 @@
+package gettext
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/go-gettext/pluralforms"
+)
+
+// HeaderError records a malformed field in the header entry of a
+// catalog.
+type HeaderError struct {
+	// Field is the name of the header field, such as
+	// "Plural-Forms".
+	Field string
+	// Param is the parameter of the field at fault, such as
+	// "nplurals", or empty if the problem is not specific to a
+	// parameter.
+	Param string
+	// Err describes the problem.  Invalid plural expressions are
+	// reported as a *pluralforms.SyntaxError.
+	Err error
+}
+
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("%s header: %v", e.Field, e.Err)
+}
+
+func (e *HeaderError) Unwrap() error {
+	return e.Err
+}
+
+// Header holds the fields of the header entry of a catalog.
+type Header struct {
+	// Fields holds the header fields, keyed by the lower case
+	// field name.
+	Fields map[string]string
+	// Charset is the charset parameter of the Content-Type field,
+	// or empty if there is none.
+	Charset string
+	// NPlurals is the number of plural forms declared by the
+	// Plural-Forms field, or zero if there is no such field.
+	NPlurals int
+	// Plural is the plural expression of the Plural-Forms field,
+	// or nil if there is no such field.
+	Plural pluralforms.Expression
+}
+
+// ParseHeader parses the header entry of a catalog in the same way as
+// ParseMO and ParsePO do.  A *HeaderError is returned for a malformed
+// Content-Type or Plural-Forms field, or an unsupported charset.
+func ParseHeader(header string) (*Header, error) {
+	var info catalogInfo
+	if err := info.read_info(header); err != nil {
+		return nil, err
+	}
+	return &Header{
+		Fields:   info.info,
+		Charset:  info.charset,
+		NPlurals: info.nplurals,
+		Plural:   info.pluralforms,
+	}, nil
+}
+
+// parseHeaderFields splits the header entry of a catalog into its
+// fields, keyed by the lower case field name.  Lines that start with
+// whitespace, or do not start with a field name followed by a colon,
+// continue the value of the previous field, so a folded value such as
+// "plural=n==1 ? 0 : 1" is not mistaken for a field.  Continuation
+// lines before the first field are ignored, as are blank lines.
+func parseHeaderFields(header string) map[string]string {
+	fields := make(map[string]string)
+	lastKey := ""
+	for _, line := range strings.Split(header, "\n") {
+		folded := line != "" && (line[0] == ' ' || line[0] == '\t')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		pos := strings.IndexByte(line, ':')
+		if !folded && pos >= 0 && isHeaderToken(strings.TrimSpace(line[:pos])) {
+			lastKey = strings.ToLower(strings.TrimSpace(line[:pos]))
+			fields[lastKey] = strings.TrimSpace(line[pos+1:])
+		} else if lastKey != "" {
+			fields[lastKey] += "\n" + line
+		}
+	}
+	return fields
+}
+
+// isHeaderToken returns true if name is a valid header field name: a
+// non-empty sequence of the token characters of RFC 7230.
+func isHeaderToken(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseHeaderParams parses the semicolon separated parameters of a
+// header field, such as "nplurals=2; plural=n != 1;".  Parameter
+// names are returned in lower case, and values may be quoted.
+// Segments without an equals sign, such as the media type of the
+// Content-Type field, are skipped.
+func parseHeaderParams(field, value string) (map[string]string, error) {
+	params := make(map[string]string)
+	for value != "" {
+		end := strings.IndexAny(value, "=;")
+		if end < 0 || value[end] == ';' {
+			// Skip a segment without a value
+			if end < 0 {
+				break
+			}
+			value = value[end+1:]
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(value[:end]))
+		value = strings.TrimLeft(value[end+1:], " \t\n")
+
+		var v string
+		if strings.HasPrefix(value, `"`) {
+			var err error
+			v, value, err = unquoteHeaderParam(value)
+			if err != nil {
+				return nil, &HeaderError{Field: field, Param: name, Err: err}
+			}
+			value = strings.TrimLeft(value, " \t\n")
+			if value != "" && value[0] != ';' {
+				return nil, &HeaderError{Field: field, Param: name, Err: fmt.Errorf("unexpected text after quoted %s value", name)}
+			}
+		} else {
+			end := strings.IndexByte(value, ';')
+			if end < 0 {
+				end = len(value)
+			}
+			v = strings.TrimSpace(value[:end])
+			value = value[end:]
+		}
+		value = strings.TrimPrefix(value, ";")
+
+		if name == "" {
+			return nil, &HeaderError{Field: field, Err: fmt.Errorf("parameter value %q has no name", v)}
+		}
+		if _, ok := params[name]; ok {
+			return nil, &HeaderError{Field: field, Param: name, Err: fmt.Errorf("duplicate %s parameter", name)}
+		}
+		params[name] = v
+	}
+	return params, nil
+}
+
+// unquoteHeaderParam reads the quoted string at the start of s,
+// returning its value and the rest of s.  A backslash escapes the
+// following character.
+func unquoteHeaderParam(s string) (value, rest string, err error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] == '"' {
+			return b.String(), s[i+1:], nil
+		}
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return "", "", fmt.Errorf("unterminated quoted value")
+}
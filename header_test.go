@@ -0,0 +1,199 @@
+package gettext
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/snapcore/go-gettext/pluralforms"
+)
+
+func TestParseHeaderFields(t *testing.T) {
+	fields := parseHeaderFields("Project-Id-Version: test\n" +
+		"content-type: text/plain; charset=UTF-8\n" +
+		"Plural-Forms: nplurals=2;\n" +
+		"    plural=n != 1;\n" +
+		"no colon\n" +
+		"\n" +
+		"X-Empty:\n")
+	assertDeepEqual(t, fields, map[string]string{
+		"project-id-version": "test",
+		"content-type":       "text/plain; charset=UTF-8",
+		"plural-forms":       "nplurals=2;\nplural=n != 1;\nno colon",
+		"x-empty":            "",
+	})
+	assertDeepEqual(t, parseHeaderFields("no colon\n"), map[string]string{})
+
+	// Folded lines and lines without a field name continue the
+	// previous field, even if they contain a colon
+	fields = parseHeaderFields("Plural-Forms: nplurals=2;\n" +
+		"    plural=n==1 ? 0 : 1;\n" +
+		"X-Note: see\n" +
+		"plural=n==1 ? 0 : 1;\n" +
+		"\tX-Folded: value\n")
+	assertDeepEqual(t, fields, map[string]string{
+		"plural-forms": "nplurals=2;\nplural=n==1 ? 0 : 1;",
+		"x-note":       "see\nplural=n==1 ? 0 : 1;\nX-Folded: value",
+	})
+}
+
+func TestParseHeaderParams(t *testing.T) {
+	for _, test := range []struct {
+		value  string
+		params map[string]string
+	}{
+		{"", map[string]string{}},
+		{"text/plain", map[string]string{}},
+		{"text/plain; charset=UTF-8", map[string]string{"charset": "UTF-8"}},
+		{"text/plain;charset=UTF-8;", map[string]string{"charset": "UTF-8"}},
+		{"nplurals=2; plural=n != 1;", map[string]string{"nplurals": "2", "plural": "n != 1"}},
+		{"plural=(n != 1); nplurals=2", map[string]string{"nplurals": "2", "plural": "(n != 1)"}},
+		{"  NPlurals = 2 ;plural =n>1", map[string]string{"nplurals": "2", "plural": "n>1"}},
+		{"nplurals=2;\nplural=n != 1;", map[string]string{"nplurals": "2", "plural": "n != 1"}},
+		{`text/plain; charset="ISO-8859-1"`, map[string]string{"charset": "ISO-8859-1"}},
+		{`a="x;y\"z"; b=`, map[string]string{"a": `x;y"z`, "b": ""}},
+	} {
+		params, err := parseHeaderParams("Test", test.value)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.value, err)
+			continue
+		}
+		assertDeepEqual(t, params, test.params)
+	}
+}
+
+func TestParseHeaderParamsErrors(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		param string
+		err   string
+	}{
+		{`charset="UTF-8`, "charset", "Test header: unterminated quoted value"},
+		{`charset="UTF-8\"`, "charset", "Test header: unterminated quoted value"},
+		{`charset="UTF"-8`, "charset", "Test header: unexpected text after quoted charset value"},
+		{"nplurals=2; nplurals=3", "nplurals", "Test header: duplicate nplurals parameter"},
+		{"=2", "", `Test header: parameter value "2" has no name`},
+	} {
+		_, err := parseHeaderParams("Test", test.value)
+		var headerErr *HeaderError
+		if !errors.As(err, &headerErr) {
+			t.Errorf("%q: expected a HeaderError, got %v", test.value, err)
+			continue
+		}
+		assert_equal(t, headerErr.Field, "Test")
+		assert_equal(t, headerErr.Param, test.param)
+		assert_equal(t, err.Error(), test.err)
+	}
+}
+
+func TestReadInfo(t *testing.T) {
+	var catalog catalogInfo
+	err := catalog.read_info("Language: ru\n" +
+		"Content-Type: text/plain\n" +
+		"Plural-Forms: plural=\"n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2\"; nplurals=3\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.language, "ru")
+	assert_equal(t, catalog.charset, "")
+	if catalog.encoding != nil {
+		t.Error("expected no charset conversion")
+	}
+	if catalog.nplurals != 3 || catalog.pluralIndex(22) != 1 {
+		t.Errorf("unexpected plural forms: nplurals = %d, index(22) = %d", catalog.nplurals, catalog.pluralIndex(22))
+	}
+
+	// Invalid plural expressions are reported with the parameter
+	catalog = catalogInfo{}
+	err = catalog.read_info("Plural-Forms: nplurals=2; plural=n !=;\n")
+	var headerErr *HeaderError
+	if !errors.As(err, &headerErr) {
+		t.Fatalf("expected a HeaderError, got %v", err)
+	}
+	assert_equal(t, headerErr.Field, "Plural-Forms")
+	assert_equal(t, headerErr.Param, "plural")
+	var syntaxErr *pluralforms.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected a SyntaxError, got %v", err)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	h, err := ParseHeader("Content-Type: text/plain; charset=\"ISO-8859-1\"\n" +
+		"Plural-Forms: nplurals=3; plural=n%10==1 ? 0 : n ? 1 : 2;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, h.Fields["content-type"], `text/plain; charset="ISO-8859-1"`)
+	assert_equal(t, h.Charset, "ISO-8859-1")
+	assertDeepEqual(t, h.NPlurals, 3)
+	assertDeepEqual(t, h.Plural.Eval(21), 0)
+
+	h, err = ParseHeader("Language: de\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, h.Charset, "")
+	assertDeepEqual(t, h.NPlurals, 0)
+	if h.Plural != nil {
+		t.Error("expected no plural expression")
+	}
+
+	_, err = ParseHeader("Content-Type: text/plain; charset=X-UNKNOWN\n")
+	assert_equal(t, err.Error(), `Content-Type header: unsupported charset "X-UNKNOWN"`)
+}
+
+func TestParseHeaderFoldedPluralForms(t *testing.T) {
+	h, err := ParseHeader("Content-Type: text/plain; charset=UTF-8\n" +
+		"Plural-Forms: nplurals=2;\n" +
+		"    plural=n==1 ? 0 : 1;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, h.NPlurals, 2)
+	assertDeepEqual(t, h.Plural.Eval(1), 0)
+	assertDeepEqual(t, h.Plural.Eval(5), 1)
+}
+
+func TestParseHeaderLongPluralForms(t *testing.T) {
+	// Overly long expressions are rejected before lookup tables
+	// are built for them
+	plural := strings.Repeat("(n*n+n)%(n+1) + ", 40000) + "0 ? 1 : 0"
+	_, err := ParseHeader("Plural-Forms: nplurals=2; plural=" + plural + ";\n")
+	var syntaxErr *pluralforms.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected a SyntaxError, got %T", err)
+	}
+	assert_equal(t, syntaxErr.Msg, "expression longer than 4096 bytes")
+}
+
+func FuzzReadInfo(f *testing.F) {
+	f.Add("Content-Type: text/plain; charset=UTF-8\nPlural-Forms: nplurals=2; plural=n != 1;\n")
+	f.Add("Content-Type: text/plain\n")
+	f.Add("Content-Type: charset=\nPlural-Forms: plural=n>1;nplurals=2\n")
+	f.Add("Plural-Forms: nplurals=\"3\"; plural=\"n%10==1 ? 0 : n ? 1 : 2\"\n")
+	f.Add("Language: pt_BR\nPlural-Forms: nplurals=2;\n  plural=(n > 1);\n")
+	f.Add("Plural-Forms: ;;=;\"\\")
+	f.Add("Plural-Forms: nplurals=2; plural=" + strings.Repeat("(", 2000) + "n" + strings.Repeat(")", 2000) + ";\n")
+	f.Add("Plural-Forms: nplurals=2; plural=" + strings.Repeat("!", 20000) + "n;\n")
+	f.Fuzz(func(t *testing.T, header string) {
+		var catalog catalogInfo
+		if err := catalog.read_info(header); err != nil {
+			var headerErr *HeaderError
+			if !errors.As(err, &headerErr) {
+				t.Fatalf("error is not a HeaderError: %v", err)
+			}
+			return
+		}
+		if catalog.pluralforms != nil {
+			if catalog.nplurals < 1 {
+				t.Fatalf("plural forms with nplurals = %d", catalog.nplurals)
+			}
+			for n := uint64(0); n < 200; n++ {
+				if index := catalog.pluralIndex(n); index < 0 || index >= catalog.nplurals {
+					t.Fatalf("pluralIndex(%d) = %d, but nplurals = %d", n, index, catalog.nplurals)
+				}
+			}
+		}
+	})
+}
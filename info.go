@@ -0,0 +1,251 @@
+package gettext
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/snapcore/go-gettext/pluralforms"
+	"golang.org/x/text/encoding"
+)
+
+// catalogInfo holds the metadata read from a catalog's header entry.
+type catalogInfo struct {
+	info        map[string]string
+	language    string
+	pluralforms pluralforms.Expression
+	// nplurals is the number of plural forms declared by the
+	// Plural-Forms header, or zero if there is no header.
+	nplurals int
+	// pluralEntryForms is the number of forms of the catalog's
+	// plural entries, or -1 if they differ.
+	pluralEntryForms int
+	// warnings describes the entries skipped when the catalog
+	// was read.
+	warnings []error
+	// ordinals holds the CLDR ordinal rules of the catalog's
+	// language, and ordinalCategories the categories they use.
+	ordinals          *pluralforms.CLDRRules
+	ordinalCategories []pluralforms.Category
+	// cardinals holds the CLDR cardinal rules of the catalog's
+	// language, if they agree with its plural forms, and
+	// cardinalForms the plural form of each category.
+	cardinals     *pluralforms.CLDRRules
+	cardinalForms map[pluralforms.Category]int
+	charset       string
+	// encoding converts the catalog's strings to UTF-8, or is
+	// nil if they need no conversion.
+	encoding encoding.Encoding
+}
+
+// germanic is the plural rule used by catalogs without plural forms.
+var germanic, _ = pluralforms.Compile("n != 1")
+
+// formIndex returns the index of the translation selected by form,
+// or -1 to select the last translation.
+func (catalog *catalogInfo) formIndex(form form) int {
+	switch form.kind {
+	case pluralForm:
+		return catalog.pluralIndex(form.n)
+	case ordinalForm:
+		return catalog.ordinalIndex(form.n)
+	case decimalForm:
+		return catalog.decimalIndex(form.ops)
+	}
+	return 0
+}
+
+// pluralIndex returns the index of the plural form to use for n.
+func (catalog *catalogInfo) pluralIndex(n uint64) int {
+	if catalog.pluralforms != nil {
+		var index int
+		if expr, ok := catalog.pluralforms.(pluralforms.Expression64); ok {
+			index = expr.Eval64(n)
+		} else {
+			index = catalog.pluralforms.Eval(uint32(n))
+		}
+		// As with libintl, an index the header does not
+		// allow for selects the first form.
+		if index < 0 || index >= catalog.nplurals {
+			return 0
+		}
+		return index
+	}
+	// Bogus/missing pluralforms in catalog: Use the Germanic
+	// plural rule.
+	if n == 1 {
+		return 0
+	}
+	return 1
+}
+
+// read_info reads the fields of the catalog's header entry.
+func (catalog *catalogInfo) read_info(info string) error {
+	catalog.info = parseHeaderFields(info)
+	catalog.language = catalog.info["language"]
+	if contentType, ok := catalog.info["content-type"]; ok {
+		if err := catalog.readContentType(contentType); err != nil {
+			return err
+		}
+	}
+	if pluralForms, ok := catalog.info["plural-forms"]; ok {
+		if err := catalog.readPluralForms(pluralForms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readContentType reads the charset parameter of the Content-Type
+// header.  Strings of a catalog without a charset are not converted.
+func (catalog *catalogInfo) readContentType(value string) error {
+	params, err := parseHeaderParams("Content-Type", value)
+	if err != nil {
+		return err
+	}
+	catalog.charset = params["charset"]
+	enc, err := charsetEncoding(catalog.charset)
+	if err != nil {
+		return &HeaderError{Field: "Content-Type", Param: "charset", Err: err}
+	}
+	catalog.encoding = enc
+	return nil
+}
+
+// readPluralForms reads the nplurals and plural parameters of the
+// Plural-Forms header, checking that the expression only selects
+// forms within nplurals.
+func (catalog *catalogInfo) readPluralForms(value string) error {
+	params, err := parseHeaderParams("Plural-Forms", value)
+	if err != nil {
+		return err
+	}
+	nplurals, ok := params["nplurals"]
+	if !ok {
+		return &HeaderError{Field: "Plural-Forms", Param: "nplurals", Err: fmt.Errorf("missing nplurals parameter")}
+	}
+	plural, ok := params["plural"]
+	if !ok || plural == "" {
+		return &HeaderError{Field: "Plural-Forms", Param: "plural", Err: fmt.Errorf("missing plural expression")}
+	}
+	n, err := strconv.Atoi(nplurals)
+	if err != nil || n < 1 {
+		return &HeaderError{Field: "Plural-Forms", Param: "nplurals", Err: fmt.Errorf("invalid nplurals value %q", nplurals)}
+	}
+	expr, err := pluralforms.Compile(plural)
+	if err != nil {
+		return &HeaderError{Field: "Plural-Forms", Param: "plural", Err: err}
+	}
+	if err := pluralforms.CheckRange(expr, n); err != nil {
+		return &HeaderError{Field: "Plural-Forms", Param: "plural", Err: err}
+	}
+	catalog.pluralforms = expr
+	catalog.nplurals = n
+	return nil
+}
+
+// checkPluralEntry checks that a plural entry provides a translation
+// for each plural form declared by the Plural-Forms header.  Catalogs
+// skip entries that fail the check, as a warning rather than failing
+// to load.
+func (catalog *catalogInfo) checkPluralEntry(key string, numStrs int) error {
+	if catalog.nplurals != 0 && numStrs != catalog.nplurals {
+		return fmt.Errorf("message %q has %d plural forms, but nplurals = %d", key, numStrs, catalog.nplurals)
+	}
+	if catalog.pluralEntryForms == 0 {
+		catalog.pluralEntryForms = numStrs
+	} else if catalog.pluralEntryForms != numStrs {
+		catalog.pluralEntryForms = -1
+	}
+	return nil
+}
+
+// skipEntry records a warning for an entry left out of the catalog.
+func (catalog *catalogInfo) skipEntry(err error) {
+	catalog.warnings = append(catalog.warnings, err)
+}
+
+// loadWarnings returns the warnings recorded while reading the catalog.
+func (catalog *catalogInfo) loadWarnings() []error {
+	return catalog.warnings
+}
+
+// ordinalIndex returns the form of an ordinal message to use for n,
+// or -1 if the catalog's ordinal rules are not known.
+func (catalog *catalogInfo) ordinalIndex(n uint64) int {
+	if catalog.ordinals == nil {
+		return -1
+	}
+	category := catalog.ordinals.Select(pluralforms.IntegerOperands(n))
+	for form, c := range catalog.ordinalCategories {
+		if c == category {
+			return form
+		}
+	}
+	return -1
+}
+
+// decimalIndex returns the plural form to use for a decimal number.
+func (catalog *catalogInfo) decimalIndex(ops pluralforms.Operands) int {
+	if catalog.cardinals == nil {
+		return catalog.pluralIndex(ops.I)
+	}
+	category := catalog.cardinals.Select(ops)
+	if form, ok := catalog.cardinalForms[category]; ok {
+		return form
+	}
+	// Categories such as Russian "other" only hold decimal
+	// numbers, which take the form of 2 in most languages.
+	return catalog.pluralIndex(2)
+}
+
+// useLanguageRule fills in the plural rules of language the catalog
+// does not already have.  The standard plural forms for the language
+// are used for a catalog without a Plural-Forms header, unless its
+// plural entries have a different number of forms, leaving the
+// Germanic rule in place.  The CLDR ordinal and cardinal rules of the
+// language are also recorded, the latter only if they agree with the
+// catalog's plural forms.
+func (catalog *catalogInfo) useLanguageRule(language string) {
+	if catalog.pluralforms == nil && catalog.useLanguagePluralForms(language) {
+		// Any cardinal rules were checked against the
+		// previous plural forms
+		catalog.cardinals = nil
+	}
+	if catalog.ordinals == nil {
+		if rules, ok := pluralforms.OrdinalRules(language); ok {
+			catalog.ordinals = rules
+			catalog.ordinalCategories = rules.Categories()
+		}
+	}
+	if catalog.cardinals == nil {
+		if rules, ok := pluralforms.CardinalRules(language); ok {
+			expr := catalog.pluralforms
+			if expr == nil {
+				expr = germanic
+			}
+			if forms, err := rules.CheckGettext(expr); err == nil {
+				catalog.cardinals = rules
+				catalog.cardinalForms = forms
+			}
+		}
+	}
+}
+
+// useLanguagePluralForms sets the plural forms of the catalog to the
+// standard rule for language, returning true if it was used.
+func (catalog *catalogInfo) useLanguagePluralForms(language string) bool {
+	rule, ok := pluralforms.ForLanguage(language)
+	if !ok {
+		return false
+	}
+	var languageInfo catalogInfo
+	if err := languageInfo.readPluralForms(rule); err != nil {
+		return false
+	}
+	if catalog.pluralEntryForms != 0 && catalog.pluralEntryForms != languageInfo.nplurals {
+		return false
+	}
+	catalog.pluralforms = languageInfo.pluralforms
+	catalog.nplurals = languageInfo.nplurals
+	return true
+}
@@ -0,0 +1,173 @@
+package gettext
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/snapcore/go-gettext/pluralforms"
+)
+
+func poWithPluralForms(pluralForms string, forms ...string) string {
+	var b strings.Builder
+	b.WriteString("msgid \"\"\nmsgstr \"Plural-Forms: " + pluralForms + "\\n\"\n\n")
+	b.WriteString("msgid \"file\"\nmsgid_plural \"files\"\n")
+	for i, form := range forms {
+		fmt.Fprintf(&b, "msgstr[%d] %q\n", i, form)
+	}
+	return b.String()
+}
+
+func TestPluralFormsValidation(t *testing.T) {
+	for _, test := range []struct {
+		pluralForms string
+		forms       []string
+		err         string
+		warning     string
+	}{
+		{"nplurals=2; plural=n != 1;", []string{"Datei", "Dateien"}, "", ""},
+		{"nplurals=1; plural=0;", []string{"ファイル"}, "", ""},
+		{"plural=n != 1;", []string{"Datei", "Dateien"}, "Plural-Forms header: missing nplurals parameter", ""},
+		{"nplurals=2;", []string{"Datei", "Dateien"}, "Plural-Forms header: missing plural expression", ""},
+		{"nplurals=zwei; plural=n != 1;", []string{"Datei", "Dateien"}, `Plural-Forms header: invalid nplurals value "zwei"`, ""},
+		{"nplurals=0; plural=0;", []string{"Datei"}, `Plural-Forms header: invalid nplurals value "0"`, ""},
+		{"nplurals=2; plural=n==1 ? 0 : n==2 ? 1 : 2;", []string{"Datei", "Dateien"}, "Plural-Forms header: plural expression selects form 2 for n = 0, but nplurals = 2", ""},
+		{"nplurals=3; plural=n != 1;", []string{"Datei", "Dateien"}, "", `message "file" has 2 plural forms, but nplurals = 3`},
+		{"nplurals=1; plural=0;", []string{"Datei", "Dateien"}, "", `message "file" has 2 plural forms, but nplurals = 1`},
+	} {
+		src := poWithPluralForms(test.pluralForms, test.forms...)
+
+		poCatalog, poErr := ParsePO(strings.NewReader(src))
+		messages, err := ReadPO(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var mo bytes.Buffer
+		if err := (&MOEncoder{}).Encode(&mo, messages); err != nil {
+			t.Fatal(err)
+		}
+		moCatalog, moErr := ParseMOData(mo.Bytes())
+
+		for _, err := range []error{poErr, moErr} {
+			if test.err == "" {
+				if err != nil {
+					t.Errorf("%q: unexpected error: %s", test.pluralForms, err)
+				}
+			} else if err == nil || err.Error() != test.err {
+				t.Errorf("%q: expected error %q, got %v", test.pluralForms, test.err, err)
+			}
+		}
+		if test.err != "" {
+			continue
+		}
+		for _, catalog := range []Catalog{poCatalog, moCatalog} {
+			warnings := catalog.Warnings()
+			if test.warning == "" {
+				if len(warnings) != 0 {
+					t.Errorf("%q: unexpected warnings: %v", test.pluralForms, warnings)
+				}
+				continue
+			}
+			if len(warnings) != 1 || warnings[0].Error() != test.warning {
+				t.Errorf("%q: expected warning %q, got %v", test.pluralForms, test.warning, warnings)
+			}
+			// The entry is skipped, so the original message
+			// is used
+			assert_equal(t, catalog.NGettext("file", "files", 1), "file")
+			assert_equal(t, catalog.NGettext("file", "files", 2), "files")
+		}
+	}
+}
+
+func TestBadPluralEntrySkipped(t *testing.T) {
+	src := poWithPluralForms("nplurals=2; plural=n != 1;", "Datei") + `
+msgid "folder"
+msgid_plural "folders"
+msgstr[0] "Ordner"
+msgstr[1] "Ordner (mehrere)"
+`
+	catalog, err := ParsePO(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Other translations of the catalog are still used
+	assert_equal(t, catalog.NGettext("folder", "folders", 2), "Ordner (mehrere)")
+	assert_equal(t, catalog.NGettext("file", "files", 2), "files")
+	assertDeepEqual(t, len(catalog.Warnings()), 1)
+}
+
+func TestPluralIndexOutOfRange(t *testing.T) {
+	expr, err := pluralforms.Compile("n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An expression that escapes the range checked at load time
+	// selects the first form, as in libintl.
+	info := catalogInfo{pluralforms: expr, nplurals: 2}
+	assertDeepEqual(t, info.pluralIndex(1), 1)
+	assertDeepEqual(t, info.pluralIndex(2), 0)
+	assertDeepEqual(t, info.pluralIndex(5000), 0)
+}
+
+const polishFiles = `
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d plik"
+msgstr[1] "%d pliki"
+msgstr[2] "%d plików"
+`
+
+func TestLanguageRuleFromHeader(t *testing.T) {
+	catalog, err := ParsePO(strings.NewReader(`msgid ""
+msgstr "Language: pl\n"
+` + polishFiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.NGettext("%d file", "%d files", 1), "%d plik")
+	assert_equal(t, catalog.NGettext("%d file", "%d files", 3), "%d pliki")
+	assert_equal(t, catalog.NGettext("%d file", "%d files", 5), "%d plików")
+	assert_equal(t, catalog.NGettext("%d file", "%d files", 22), "%d pliki")
+}
+
+func TestLanguageRuleFromLocale(t *testing.T) {
+	translations := &TextDomain{Name: "messages", FS: fstest.MapFS{
+		"pl/LC_MESSAGES/messages.po": &fstest.MapFile{Data: []byte(polishFiles)},
+	}}
+	pl := translations.Locale("pl")
+	assert_equal(t, pl.NGettext("%d file", "%d files", 5), "%d plików")
+	assert_equal(t, pl.NGettext("%d file", "%d files", 22), "%d pliki")
+}
+
+func TestLanguageRuleMismatch(t *testing.T) {
+	// The entries don't match the Polish rule, so the Germanic
+	// rule is kept
+	catalog, err := ParsePO(strings.NewReader(`msgid ""
+msgstr "Language: pl\n"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d plik"
+msgstr[1] "%d pliki"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.NGettext("%d file", "%d files", 1), "%d plik")
+	assert_equal(t, catalog.NGettext("%d file", "%d files", 5), "%d pliki")
+}
+
+func TestPluralFormsHeaderOverridesLanguage(t *testing.T) {
+	catalog, err := ParsePO(strings.NewReader(`msgid ""
+msgstr ""
+"Language: pl\n"
+"Plural-Forms: nplurals=3; plural=n==1 ? 0 : n==2 ? 1 : 2;\n"
+` + polishFiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.NGettext("%d file", "%d files", 2), "%d pliki")
+	assert_equal(t, catalog.NGettext("%d file", "%d files", 3), "%d plików")
+}
@@ -0,0 +1,75 @@
+package gettext
+
+// Message is a single entry of a message catalog, as stored in po and
+// mo files.
+type Message struct {
+	// Context is the message context (msgctxt), or empty if the
+	// message has no context.
+	Context string
+	// HasContext is set if the message has a context, which
+	// distinguishes an empty msgctxt from no msgctxt at all.  A
+	// non-empty Context implies a context even if it is not set.
+	HasContext bool
+	// ID is the untranslated message (msgid).  The catalog
+	// header is stored as a message with an empty ID.
+	ID string
+	// IDPlural is the untranslated plural message
+	// (msgid_plural), or empty if the message has no plural forms.
+	IDPlural string
+	// Str holds the translations of the message (msgstr).  Plural
+	// messages have one translation per plural form.
+	Str []string
+
+	// Comments holds the translator comments of the entry.
+	Comments []string
+	// ExtractedComments holds comments extracted from the
+	// program source.
+	ExtractedComments []string
+	// References holds the source locations the message was
+	// extracted from, in file:line form.
+	References []string
+	// Flags holds flags such as "fuzzy" or "c-format".
+	Flags []string
+	// Obsolete is set for entries that are no longer used by the
+	// program, but kept for future reference.
+	Obsolete bool
+}
+
+// HasFlag returns true if the message carries the given flag.
+func (m *Message) HasFlag(flag string) bool {
+	for _, f := range m.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHeader returns true if the message is the catalog header entry.
+func (m *Message) IsHeader() bool {
+	return m.ID == "" && !m.hasContext()
+}
+
+// IsOrdinal returns true if the message is an ordinal message, as
+// looked up by Catalog.Ordinal and Catalog.POrdinal.
+func (m *Message) IsOrdinal() bool {
+	return isOrdinalContext(m.Context)
+}
+
+// IsTranslated returns true if the message has a translation.
+func (m *Message) IsTranslated() bool {
+	return len(m.Str) != 0 && m.Str[0] != ""
+}
+
+func (m *Message) hasContext() bool {
+	return m.HasContext || m.Context != ""
+}
+
+// key returns the key used to look up the message in a catalog,
+// combining the context and message ID.
+func (m *Message) key() string {
+	if m.hasContext() {
+		return m.Context + "\x04" + m.ID
+	}
+	return m.ID
+}
@@ -4,11 +4,12 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"runtime"
 	"sort"
 	"strings"
-
-	"github.com/snapcore/go-gettext/pluralforms"
 )
 
 const le_magic = 0x950412de
@@ -41,38 +42,67 @@ type mocatalog struct {
 	transTab   []byte
 	hashTab    []byte
 
-	info        map[string]string
-	language    string
-	pluralforms pluralforms.Expression
-	charset     string
+	// sysdepOrig and sysdepTrans hold the expanded system
+	// dependent strings of a major revision 1 file, which follow
+	// the numStrings static strings in entry order.  sysdepIndex
+	// maps their message IDs to entry indexes.
+	sysdepOrig  [][]byte
+	sysdepTrans [][]byte
+	sysdepIndex map[string]int
+
+	// skipped holds the indexes of entries left out of the
+	// catalog, which lookups treat as missing.
+	skipped map[int]bool
+
+	catalogInfo
 }
 
-func (catalog *mocatalog) findMsg(msgid string, usePlural bool, n uint32) (msgstr string, ok bool) {
+func (catalog *mocatalog) findMsg(msgid string, form form) (msgstr string, ok bool) {
 	idx, ok := catalog.msgIndex(msgid)
-	if !ok {
+	if !ok || catalog.skipped[idx] {
 		return "", false
 	}
-	var plural int
-	if usePlural {
-		if catalog.pluralforms != nil {
-			plural = catalog.pluralforms.Eval(n)
-		} else {
-			// Bogus/missing pluralforms in mo: Use the Germanic
-			// plural rule.
-			if n == 1 {
-				plural = 0
-			} else {
-				plural = 1
-			}
-		}
+	index := catalog.formIndex(form)
+	if index < 0 {
+		index = bytes.Count(catalog.translation(idx), []byte{0})
+	}
+	msgstr = catalog.decode(catalog.msgStr(idx, index))
+	// The mapping must not be finalized while its data is in use
+	runtime.KeepAlive(catalog.m)
+	return msgstr, true
+}
+
+// tableString returns the idx'th string referenced by a string table.
+func (catalog *mocatalog) tableString(table []byte, idx int) []byte {
+	strLen := catalog.order.Uint32(table[8*idx:])
+	strOffset := catalog.order.Uint32(table[8*idx+4:])
+	return catalog.m.data[strOffset : strOffset+strLen]
+}
+
+// numEntries returns the number of entries of the catalog, including
+// system dependent strings.
+func (catalog *mocatalog) numEntries() int {
+	return catalog.numStrings + len(catalog.sysdepOrig)
+}
+
+// original returns the original string of the idx'th entry.
+func (catalog *mocatalog) original(idx int) []byte {
+	if idx >= catalog.numStrings {
+		return catalog.sysdepOrig[idx-catalog.numStrings]
+	}
+	return catalog.tableString(catalog.origTab, idx)
+}
+
+// translation returns the translated string of the idx'th entry.
+func (catalog *mocatalog) translation(idx int) []byte {
+	if idx >= catalog.numStrings {
+		return catalog.sysdepTrans[idx-catalog.numStrings]
 	}
-	return string(catalog.msgStr(idx, plural)), true
+	return catalog.tableString(catalog.transTab, idx)
 }
 
 func (catalog *mocatalog) msgID(idx int) []byte {
-	strLen := catalog.order.Uint32(catalog.origTab[8*idx:])
-	strOffset := catalog.order.Uint32(catalog.origTab[8*idx+4:])
-	msgid := catalog.m.data[strOffset : strOffset+strLen]
+	msgid := catalog.original(idx)
 
 	zero := bytes.IndexByte(msgid, '\x00')
 	if zero >= 0 {
@@ -82,9 +112,7 @@ func (catalog *mocatalog) msgID(idx int) []byte {
 }
 
 func (catalog *mocatalog) msgStr(idx, n int) []byte {
-	strLen := catalog.order.Uint32(catalog.transTab[8*idx:])
-	strOffset := catalog.order.Uint32(catalog.transTab[8*idx+4:])
-	msgstr := catalog.m.data[strOffset : strOffset+strLen]
+	msgstr := catalog.translation(idx)
 
 	for ; n >= 0; n-- {
 		zero := bytes.IndexByte(msgstr, '\x00')
@@ -102,6 +130,28 @@ func (catalog *mocatalog) msgStr(idx, n int) []byte {
 	return msgstr
 }
 
+// messages returns all the entries of the catalog.
+func (catalog *mocatalog) messages() []Message {
+	messages := make([]Message, catalog.numEntries())
+	for idx := range messages {
+		msg := &messages[idx]
+		orig := strings.SplitN(string(catalog.original(idx)), "\x00", 2)
+		if len(orig) == 2 {
+			msg.IDPlural = orig[1]
+		}
+		key := strings.SplitN(orig[0], "\x04", 2)
+		if len(key) == 2 {
+			msg.Context = key[0]
+			msg.HasContext = true
+			msg.ID = key[1]
+		} else {
+			msg.ID = key[0]
+		}
+		msg.Str = strings.Split(string(catalog.translation(idx)), "\x00")
+	}
+	return messages
+}
+
 // hashString implements libintl's hash_string() algorithm
 func hashString(s string) uint32 {
 	const hashWordBits = 32
@@ -120,30 +170,21 @@ func hashString(s string) uint32 {
 }
 
 func (catalog *mocatalog) msgIndex(msgid string) (idx int, ok bool) {
+	if idx, ok := catalog.staticIndex(msgid); ok {
+		return idx, true
+	}
+	// System dependent strings are not in the hash table or
+	// sorted with the static strings
+	idx, ok = catalog.sysdepIndex[msgid]
+	return idx, ok
+}
+
+// staticIndex looks up msgid among the static strings of the catalog.
+func (catalog *mocatalog) staticIndex(msgid string) (idx int, ok bool) {
 	// Use the hash table if available
 	if catalog.hashTab != nil {
-		// Hash table lookup adapted from libintl's _nl_find_msg()
-		hval := hashString(msgid)
-		hashSize := uint32(len(catalog.hashTab) / 4)
-		idx := hval % hashSize
-		incr := 1 + (hval % (hashSize - 2))
-
-		for {
-			nstr := catalog.order.Uint32(catalog.hashTab[4*idx:])
-			if nstr == 0 {
-				// Hash table entry is empty
-				return 0, false
-			}
-
-			nstr -= 1
-			if string(catalog.msgID(int(nstr))) == msgid {
-				return int(nstr), true
-			}
-			if idx >= hashSize-incr {
-				idx -= hashSize - incr
-			} else {
-				idx += incr
-			}
+		if idx, ok, done := catalog.hashIndex(msgid); done {
+			return idx, ok
 		}
 	}
 
@@ -157,61 +198,96 @@ func (catalog *mocatalog) msgIndex(msgid string) (idx int, ok bool) {
 	return 0, false
 }
 
-func (catalog *mocatalog) read_info(info string) error {
-	catalog.info = make(map[string]string)
-	lastk := ""
-	for _, line := range strings.Split(info, "\n") {
-		item := strings.TrimSpace(line)
-		if len(item) == 0 {
-			continue
+// hashIndex looks up msgid in the hash table.  If the probe sequence
+// visits every bucket it reaches without finding msgid or an empty
+// bucket, which can happen if the table size is not prime, done is
+// false as the table cannot tell whether msgid is present.
+func (catalog *mocatalog) hashIndex(msgid string) (idx int, ok, done bool) {
+	// Hash table lookup adapted from libintl's _nl_find_msg()
+	hval := hashString(msgid)
+	hashSize := uint32(len(catalog.hashTab) / 4)
+	bucket := hval % hashSize
+	incr := 1 + (hval % (hashSize - 2))
+
+	for probe := uint32(0); probe < hashSize; probe++ {
+		nstr := catalog.order.Uint32(catalog.hashTab[4*bucket:])
+		if nstr == 0 {
+			// Hash table entry is empty
+			return 0, false, true
 		}
-		var k string
-		var v string
-		if strings.Contains(item, ":") {
-			tmp := strings.SplitN(item, ":", 2)
-			k = strings.ToLower(strings.TrimSpace(tmp[0]))
-			v = strings.TrimSpace(tmp[1])
-			catalog.info[k] = v
-			lastk = k
-		} else if len(lastk) != 0 {
-			catalog.info[lastk] += "\n" + item
+
+		nstr -= 1
+		if string(catalog.msgID(int(nstr))) == msgid {
+			return int(nstr), true, true
 		}
-		if k == "content-type" {
-			catalog.charset = strings.Split(v, "charset=")[1]
-		} else if k == "plural-forms" {
-			p := strings.Split(v, ";")[1]
-			s := strings.Split(p, "plural=")[1]
-			expr, err := pluralforms.Compile(s)
-			if err != nil {
-				return err
-			}
-			catalog.pluralforms = expr
+		if bucket >= hashSize-incr {
+			bucket -= hashSize - incr
+		} else {
+			bucket += incr
 		}
 	}
-	return nil
+	return 0, false, false
+}
+
+// verifyHashTable checks that every message can be found through the
+// hash table, so that a table that was not built for the catalog's
+// strings cannot hide translations.  If any message cannot be found,
+// the table is dropped and lookups use binary search instead.
+//
+// This costs one lookup per message, so is only done on request.
+func (catalog *mocatalog) verifyHashTable() bool {
+	if catalog.hashTab == nil {
+		return true
+	}
+	for i := 0; i < catalog.numStrings; i++ {
+		msgid := string(catalog.msgID(i))
+		if _, ok, _ := catalog.hashIndex(msgid); !ok {
+			catalog.hashTab = nil
+			return false
+		}
+	}
+	return true
+}
+
+// tableData returns the count entries of the given size starting at
+// offset, or false if they do not lie within data.  The end of the
+// table is computed in 64 bits so that it cannot wrap around.
+func tableData(data []byte, offset, count uint32, size uint64) ([]byte, bool) {
+	end := uint64(offset) + uint64(count)*size
+	if end > uint64(len(data)) {
+		return nil, false
+	}
+	return data[offset:end], true
 }
 
 func validateStringTable(m *fileMapping, table []byte, numStrings int, order binary.ByteOrder) error {
 	for i := 0; i < numStrings; i++ {
 		strLen := order.Uint32(table[8*i:])
 		strOffset := order.Uint32(table[8*i+4:])
-		if int(strLen+strOffset) > len(m.data) {
+		if _, ok := tableData(m.data, strOffset, strLen, 1); !ok {
 			return fmt.Errorf("string %d data (len=%x, offset=%x) is out of bounds", i, strLen, strOffset)
 		}
 	}
 	return nil
 }
 
-func validateHashTable(table []byte, numStrings int, order binary.ByteOrder) error {
-	for i := 0; i < numStrings; i++ {
+// validateHashTable checks that the entries of a hash table refer to
+// strings of the catalog.  The table is not usable if it has no empty
+// bucket, as libintl's lookup stops at the first empty bucket on the
+// probe sequence of a missing message.
+func validateHashTable(table []byte, numStrings int, order binary.ByteOrder) (usable bool, err error) {
+	for i := 0; i < len(table)/4; i++ {
 		strIndex := order.Uint32(table[4*i:])
 		// hash entries are either zero or a string index
 		// incremented by one
-		if int(strIndex) >= numStrings+1 {
-			return fmt.Errorf("hash table is corrupt")
+		if uint64(strIndex) >= uint64(numStrings)+1 {
+			return false, fmt.Errorf("hash table is corrupt")
+		}
+		if strIndex == 0 {
+			usable = true
 		}
 	}
-	return nil
+	return usable, nil
 }
 
 // ParseMO parses a mo file into a Catalog if possible.
@@ -220,7 +296,69 @@ func ParseMO(file *os.File) (Catalog, error) {
 	if err != nil {
 		return Catalog{}, err
 	}
-	return Catalog{[]*mocatalog{mo}}, nil
+	return newCatalog([]msgCatalog{mo}), nil
+}
+
+// ParseMOData parses a mo file held in memory into a Catalog.
+//
+// The catalog refers to data directly rather than copying it, so the
+// slice must not be modified while the catalog is in use.
+func ParseMOData(data []byte) (Catalog, error) {
+	mo, err := parseMOData(data)
+	if err != nil {
+		return Catalog{}, err
+	}
+	return newCatalog([]msgCatalog{mo}), nil
+}
+
+// ParseMOReaderAt parses the size bytes of a mo file read from r
+// into a Catalog.
+func ParseMOReaderAt(r io.ReaderAt, size int64) (Catalog, error) {
+	if size < 0 {
+		return Catalog{}, fmt.Errorf("message catalogue has negative size")
+	}
+	if size != int64(int(size)) {
+		return Catalog{}, fmt.Errorf("message catalogue is too large")
+	}
+	// Check the header before trusting size, and read the rest
+	// of the file incrementally, so that a bogus size cannot
+	// allocate more memory than the data actually read.
+	headerSize := int64(binary.Size(header{}))
+	if size < headerSize {
+		return Catalog{}, fmt.Errorf("message catalogue is too short")
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, io.NewSectionReader(r, 0, size), headerSize); err != nil {
+		return Catalog{}, err
+	}
+	if _, err := moByteOrder(buf.Bytes()); err != nil {
+		return Catalog{}, err
+	}
+	n, err := buf.ReadFrom(io.NewSectionReader(r, headerSize, size-headerSize))
+	if err != nil {
+		return Catalog{}, err
+	}
+	if n != size-headerSize {
+		return Catalog{}, io.ErrUnexpectedEOF
+	}
+	return ParseMOData(buf.Bytes())
+}
+
+// ReadMO reads all the entries of a mo file.  Message contexts and
+// plural forms are split out into the fields of each Message.
+//
+// Only the structure of the file is checked, so entries can be read
+// from catalogs with a header that ParseMO rejects.
+func ReadMO(r io.Reader) ([]Message, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	mo, err := parseMOTables(&fileMapping{data: data})
+	if err != nil {
+		return nil, err
+	}
+	return mo.messages(), nil
 }
 
 func parseMO(file *os.File) (*mocatalog, error) {
@@ -228,6 +366,46 @@ func parseMO(file *os.File) (*mocatalog, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseMapping(m)
+}
+
+func parseMOData(data []byte) (*mocatalog, error) {
+	return parseMapping(&fileMapping{data: data})
+}
+
+// moByteOrder returns the byte order of a mo file, as given by the
+// magic number at the start of data.
+func moByteOrder(data []byte) (binary.ByteOrder, error) {
+	switch magic := binary.LittleEndian.Uint32(data); magic {
+	case le_magic:
+		return binary.LittleEndian, nil
+	case be_magic:
+		return binary.BigEndian, nil
+	default:
+		return nil, fmt.Errorf("Wrong magic: %d", magic)
+	}
+}
+
+// parseMapping parses the catalog held in m, reading its header entry
+// and checking its plural entries.  The mapping is owned by the
+// returned catalog, or closed on error.
+func parseMapping(m *fileMapping) (*mocatalog, error) {
+	catalog, err := parseMOTables(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := catalog.readContent(); err != nil {
+		catalog.m.Close()
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// parseMOTables parses the string and hash tables of the catalog held
+// in m, including any system dependent strings, without interpreting
+// the entries.  The mapping is owned by the returned catalog, or
+// closed on error.
+func parseMOTables(m *fileMapping) (*mocatalog, error) {
 	defer func() {
 		if m != nil {
 			m.Close()
@@ -240,15 +418,9 @@ func parseMO(file *os.File) (*mocatalog, error) {
 		return nil, fmt.Errorf("message catalogue is too short")
 	}
 
-	var order binary.ByteOrder = binary.LittleEndian
-	magic := order.Uint32(m.data)
-	switch magic {
-	case le_magic:
-		// nothing
-	case be_magic:
-		order = binary.BigEndian
-	default:
-		return nil, fmt.Errorf("Wrong magic: %d", magic)
+	order, err := moByteOrder(m.data)
+	if err != nil {
+		return nil, err
 	}
 	if err := binary.Read(bytes.NewBuffer(m.data[:headerSize]), order, &header); err != nil {
 		return nil, err
@@ -261,31 +433,36 @@ func parseMO(file *os.File) (*mocatalog, error) {
 	}
 	numStrings := int(header.NumStrings)
 
-	if int(header.OrigTabOffset+8*header.NumStrings) > len(m.data) {
+	origTab, ok := tableData(m.data, header.OrigTabOffset, header.NumStrings, 8)
+	if !ok {
 		return nil, fmt.Errorf("original strings table out of bounds")
 	}
-	origTab := m.data[header.OrigTabOffset : header.OrigTabOffset+8*header.NumStrings]
 	if err := validateStringTable(m, origTab, numStrings, order); err != nil {
 		return nil, err
 	}
 
-	if int(header.TransTabOffset+8*header.NumStrings) > len(m.data) {
+	transTab, ok := tableData(m.data, header.TransTabOffset, header.NumStrings, 8)
+	if !ok {
 		return nil, fmt.Errorf("translated strings table out of bounds")
 	}
-	transTab := m.data[header.TransTabOffset : header.TransTabOffset+8*header.NumStrings]
 	if err := validateStringTable(m, transTab, numStrings, order); err != nil {
 		return nil, err
 	}
 
 	var hashTab []byte
 	if header.HashTabSize > 2 {
-		if int(header.HashTabOffset+4*header.HashTabSize) > len(m.data) {
+		hashTab, ok = tableData(m.data, header.HashTabOffset, header.HashTabSize, 4)
+		if !ok {
 			return nil, fmt.Errorf("hash table out of bounds")
 		}
-		hashTab = m.data[header.HashTabOffset : header.HashTabOffset+4*header.HashTabSize]
-		if err := validateHashTable(hashTab, numStrings, order); err != nil {
+		usable, err := validateHashTable(hashTab, numStrings, order)
+		if err != nil {
 			return nil, err
 		}
+		if !usable {
+			// Fall back to binary search
+			hashTab = nil
+		}
 	}
 
 	catalog := &mocatalog{
@@ -297,13 +474,48 @@ func parseMO(file *os.File) (*mocatalog, error) {
 		transTab:   transTab,
 		hashTab:    hashTab,
 	}
-	// Read catalog header if available
-	if catalog.numStrings > 0 && len(catalog.msgID(0)) == 0 {
-		if err := catalog.read_info(string(catalog.msgStr(0, 0))); err != nil {
+	if header.get_major_version() == 1 {
+		catalog.sysdepOrig, catalog.sysdepTrans, err = readSysdepStrings(m.data, order)
+		if err != nil {
 			return nil, err
 		}
+		catalog.sysdepIndex = make(map[string]int, len(catalog.sysdepOrig))
+		for i := range catalog.sysdepOrig {
+			idx := catalog.numStrings + i
+			msgid := string(catalog.msgID(idx))
+			if _, ok := catalog.sysdepIndex[msgid]; !ok {
+				catalog.sysdepIndex[msgid] = idx
+			}
+		}
 	}
 
 	m = nil
 	return catalog, nil
 }
+
+// readContent reads the header entry of the catalog and checks its
+// plural entries, skipping those with the wrong number of forms.
+func (catalog *mocatalog) readContent() error {
+	// Read catalog header if available
+	if catalog.numStrings > 0 && len(catalog.msgID(0)) == 0 {
+		if err := catalog.read_info(string(catalog.msgStr(0, 0))); err != nil {
+			return err
+		}
+	}
+	for idx := 0; idx < catalog.numEntries(); idx++ {
+		orig := catalog.original(idx)
+		if bytes.IndexByte(orig, '\x00') < 0 || isOrdinalKey(string(catalog.msgID(idx))) {
+			continue
+		}
+		numStrs := bytes.Count(catalog.translation(idx), []byte{0}) + 1
+		if err := catalog.checkPluralEntry(string(catalog.msgID(idx)), numStrs); err != nil {
+			catalog.skipEntry(err)
+			if catalog.skipped == nil {
+				catalog.skipped = make(map[int]bool)
+			}
+			catalog.skipped[idx] = true
+		}
+	}
+	catalog.useLanguageRule(catalog.language)
+	return nil
+}
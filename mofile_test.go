@@ -1,9 +1,17 @@
 package gettext
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestEnGettext(t *testing.T) {
@@ -104,3 +112,237 @@ func TestJaNGettext(t *testing.T) {
 		"ビールを2杯ください",
 	)
 }
+
+func TestParseMOData(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := ParseMOData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Gettext("greeting"), "Hello")
+	assert_equal(t, catalog.NGettext("order %d beer", "order %d beers", 2), "%d beers please")
+
+	// Truncated data fails validation
+	_, err = ParseMOData(data[:len(data)/2])
+	if err == nil {
+		t.Fatal("expected error parsing truncated catalog")
+	}
+}
+
+func TestParseMOReaderAt(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/es/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := ParseMOReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.PGettext("knot", "bow"), "lazo")
+
+	// Reading past the end of the reader is an error
+	_, err = ParseMOReaderAt(bytes.NewReader(data), int64(len(data)+1))
+	if err == nil {
+		t.Fatal("expected error reading past end of data")
+	}
+
+	// A bogus size does not allocate a buffer of that size
+	_, err = ParseMOReaderAt(bytes.NewReader(data), math.MaxInt64)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	_, err = ParseMOReaderAt(bytes.NewReader([]byte("not a mo file, but long enough")), 1<<60)
+	if err == nil {
+		t.Fatal("expected error for data with wrong magic number")
+	}
+}
+
+func TestReadMO(t *testing.T) {
+	file, err := os.Open("testdata/es/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	messages, err := ReadMO(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, len(messages), 5)
+	assert_equal(t, messages[0].ID, "")
+	assert_equal(t, messages[0].Str[0], "Language: es\nMIME-Version: 1.0\nContent-Type: text/plain; charset=UTF-8\nContent-Transfer-Encoding: 8bit\nPlural-Forms: nplurals=2; plural=(n != 1);\n")
+	assertDeepEqual(t, messages[1], Message{
+		Context:    "knot",
+		HasContext: true,
+		ID:         "%d bow",
+		IDPlural:   "%d bows",
+		Str:        []string{"%d lazo", "%d lazos"},
+	})
+	assertDeepEqual(t, messages[2], Message{
+		Context:    "knot",
+		HasContext: true,
+		ID:         "bow",
+		Str:        []string{"lazo"},
+	})
+}
+
+// moHeader returns a little endian mo file header, followed by the
+// given words.
+func moHeader(numStrings, origTab, transTab, hashSize, hashTab uint32, words ...uint32) []byte {
+	var buf bytes.Buffer
+	for _, word := range append([]uint32{le_magic, 0, numStrings, origTab, transTab, hashSize, hashTab}, words...) {
+		binary.Write(&buf, binary.LittleEndian, word)
+	}
+	return buf.Bytes()
+}
+
+func TestParseMOOverflow(t *testing.T) {
+	for _, test := range []struct {
+		data []byte
+		err  string
+	}{
+		// Table offsets that wrap around to the start of the file
+		{moHeader(1, 0xfffffff8, 28, 0, 0), "original strings table out of bounds"},
+		{moHeader(1, 28, 0xfffffff8, 0, 0, 0, 0), "translated strings table out of bounds"},
+		{moHeader(0x20000000, 28, 28, 0, 0), "original strings table out of bounds"},
+		{moHeader(0, 28, 28, 0x40000000, 28), "hash table out of bounds"},
+		{moHeader(0, 28, 28, 3, 0xfffffffc), "hash table out of bounds"},
+		// String data that wraps around
+		{moHeader(1, 28, 36, 0, 0, 0xffffffff, 1, 0, 0), "string 0 data (len=ffffffff, offset=1) is out of bounds"},
+		{moHeader(1, 28, 36, 0, 0, 0, 0, 1, 0xffffffff), "string 0 data (len=1, offset=ffffffff) is out of bounds"},
+		// A hash table larger than the number of strings
+		{moHeader(1, 28, 36, 3, 44, 0, 0, 0, 0, 0, 0, 5), "hash table is corrupt"},
+	} {
+		_, err := ParseMOData(test.data)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("expected error %q, got %v", test.err, err)
+		}
+	}
+}
+
+// encodeTestMO compiles a po file from testdata, returning the mo
+// data along with the offset and size of its hash table.
+func encodeTestMO(t *testing.T, name string) (data []byte, hashOffset, hashSize uint32) {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	messages, err := ReadPO(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := (&MOEncoder{}).Encode(&buf, messages); err != nil {
+		t.Fatal(err)
+	}
+	data = buf.Bytes()
+	return data, binary.LittleEndian.Uint32(data[24:]), binary.LittleEndian.Uint32(data[20:])
+}
+
+func TestHashTableWithoutEmptyBucket(t *testing.T) {
+	// A hash table whose buckets all refer to the header entry
+	data := moHeader(1, 28, 36, 3, 44, 0, 56, 0, 56, 1, 1, 1)
+	catalog, err := ParseMOData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Gettext("missing"), "missing")
+
+	// Filling the empty buckets of a real catalog's table makes
+	// it unusable, so binary search is used instead
+	data, hashOffset, hashSize := encodeTestMO(t, "testdata/en/messages.po")
+	for i := uint32(0); i < hashSize; i++ {
+		bucket := data[hashOffset+4*i:]
+		if binary.LittleEndian.Uint32(bucket) == 0 {
+			binary.LittleEndian.PutUint32(bucket, 1)
+		}
+	}
+	mo, err := parseMOData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mo.hashTab != nil {
+		t.Error("expected the hash table to be dropped")
+	}
+	catalog = newCatalog([]msgCatalog{mo})
+	assert_equal(t, catalog.Gettext("greeting"), "Hello")
+	assert_equal(t, catalog.Gettext("missing"), "missing")
+}
+
+func TestVerifyHashTable(t *testing.T) {
+	data, hashOffset, hashSize := encodeTestMO(t, "testdata/en/messages.po")
+	mo, err := parseMOData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mo.verifyHashTable() || mo.hashTab == nil {
+		t.Error("expected the hash table to be verified")
+	}
+
+	// Rotate the buckets, so that messages are no longer found
+	// on their probe sequence
+	table := data[hashOffset : hashOffset+4*hashSize]
+	rotated := append(append([]byte{}, table[4:]...), table[:4]...)
+	copy(table, rotated)
+	mo, err = parseMOData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mo.verifyHashTable() || mo.hashTab != nil {
+		t.Error("expected the hash table to be dropped")
+	}
+	catalog := newCatalog([]msgCatalog{mo})
+	assert_equal(t, catalog.Gettext("greeting"), "Hello")
+
+	// TextDomain verifies hash tables on request
+	translations := &TextDomain{Name: "messages", VerifyHashTables: true, FS: fstest.MapFS{
+		"en/LC_MESSAGES/messages.mo": &fstest.MapFile{Data: data},
+	}}
+	assert_equal(t, translations.Locale("en").Gettext("greeting"), "Hello")
+}
+
+func FuzzParseMO(f *testing.F) {
+	files, err := filepath.Glob("testdata/*/*.mo")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	// Catalogs with deeply nested plural expressions
+	for _, plural := range []string{
+		strings.Repeat("(", 2000) + "n" + strings.Repeat(")", 2000),
+		strings.Repeat("!", 20000) + "n",
+	} {
+		var buf bytes.Buffer
+		messages := []Message{
+			{Str: []string{"Content-Type: text/plain; charset=UTF-8\nPlural-Forms: nplurals=2; plural=" + plural + ";\n"}},
+			{ID: "file", IDPlural: "files", Str: []string{"fichier", "fichiers"}},
+		}
+		if err := (&MOEncoder{}).Encode(&buf, messages); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		mo, err := parseMOData(data)
+		if err != nil {
+			return
+		}
+		catalog := newCatalog([]msgCatalog{mo})
+		for _, msg := range mo.messages() {
+			catalog.PGettext(msg.Context, msg.ID)
+			catalog.NPGettext(msg.Context, msg.ID, msg.IDPlural, 2)
+			catalog.NGettextDecimal(msg.ID, msg.IDPlural, "1.5")
+			catalog.Ordinal(msg.ID, 3)
+		}
+		catalog.Gettext("missing")
+	})
+}
@@ -0,0 +1,190 @@
+package gettext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// sysdepHeader holds the fields following the header of a major
+// revision 1 mo file, which describe its system dependent strings.
+type sysdepHeader struct {
+	NumSegments          uint32
+	SegmentsOffset       uint32
+	NumStrings           uint32
+	OrigSysdepTabOffset  uint32
+	TransSysdepTabOffset uint32
+}
+
+// segmentsEnd marks the last segment pair of a system dependent
+// string.
+const segmentsEnd = 0xffffffff
+
+// maxSysdepExpansion limits the work of expanding the system dependent
+// strings of a file to this multiple of its size.  The descriptors
+// and segments of a well formed file do not overlap, so expanding
+// them reads each byte about once.  A crafted file could otherwise
+// refer every string to the same large segments, taking time and
+// memory quadratic in its size.
+const maxSysdepExpansion = 4
+
+// sysdepSegmentValue returns the expansion of a system dependent
+// segment for Go's fmt package, as libintl's get_sysdep_segment_value
+// does for C.  The segments are the names of the <inttypes.h> PRI
+// macros used in messages such as "%<PRIu64> bytes", and the glibc
+// specific "I" flag.  All integer types are formatted by the same
+// verbs in Go.
+func sysdepSegmentValue(name string) (string, bool) {
+	if name == "I" {
+		// Go has no flag to use locale specific digits
+		return "", true
+	}
+	if !strings.HasPrefix(name, "PRI") || len(name) < 4 {
+		return "", false
+	}
+	var verb string
+	switch name[3] {
+	case 'd', 'i', 'u':
+		verb = "d"
+	case 'o', 'x', 'X':
+		verb = name[3:4]
+	default:
+		return "", false
+	}
+	size := name[4:]
+	if s, ok := strings.CutPrefix(size, "LEAST"); ok {
+		size = s
+	} else if s, ok := strings.CutPrefix(size, "FAST"); ok {
+		size = s
+	} else if size == "MAX" || size == "PTR" {
+		return verb, true
+	}
+	switch size {
+	case "8", "16", "32", "64":
+		return verb, true
+	}
+	return "", false
+}
+
+// readSysdepStrings reads and expands the system dependent strings of
+// a major revision 1 mo file.  Strings using segments that cannot be
+// expanded are skipped, as libintl does.
+func readSysdepStrings(data []byte, order binary.ByteOrder) (orig, trans [][]byte, err error) {
+	var sysdep sysdepHeader
+	headerSize := binary.Size(header{})
+	if len(data) < headerSize+binary.Size(&sysdep) {
+		return nil, nil, fmt.Errorf("message catalogue is too short")
+	}
+	if err := binary.Read(bytes.NewReader(data[headerSize:]), order, &sysdep); err != nil {
+		return nil, nil, err
+	}
+	if sysdep.NumStrings == 0 {
+		return nil, nil, nil
+	}
+
+	segTab, ok := tableData(data, sysdep.SegmentsOffset, sysdep.NumSegments, 8)
+	if !ok {
+		return nil, nil, fmt.Errorf("system dependent segment table out of bounds")
+	}
+	// A nil entry marks a segment that cannot be expanded
+	segments := make([]*string, sysdep.NumSegments)
+	for i := range segments {
+		segLen := order.Uint32(segTab[8*i:])
+		segOffset := order.Uint32(segTab[8*i+4:])
+		name, ok := tableData(data, segOffset, segLen, 1)
+		if !ok {
+			return nil, nil, fmt.Errorf("system dependent segment %d (len=%x, offset=%x) is out of bounds", i, segLen, segOffset)
+		}
+		// The length includes the trailing NUL
+		if value, ok := sysdepSegmentValue(strings.TrimRight(string(name), "\x00")); ok {
+			segments[i] = &value
+		}
+	}
+
+	origTab, ok := tableData(data, sysdep.OrigSysdepTabOffset, sysdep.NumStrings, 4)
+	if !ok {
+		return nil, nil, fmt.Errorf("original system dependent strings table out of bounds")
+	}
+	transTab, ok := tableData(data, sysdep.TransSysdepTabOffset, sysdep.NumStrings, 4)
+	if !ok {
+		return nil, nil, fmt.Errorf("translated system dependent strings table out of bounds")
+	}
+	budget := maxSysdepExpansion * uint64(len(data))
+	for i := 0; i < int(sysdep.NumStrings); i++ {
+		origStr, origOk, err := expandSysdepString(data, order, order.Uint32(origTab[4*i:]), segments, &budget)
+		if err != nil {
+			return nil, nil, fmt.Errorf("original system dependent string %d: %v", i, err)
+		}
+		transStr, transOk, err := expandSysdepString(data, order, order.Uint32(transTab[4*i:]), segments, &budget)
+		if err != nil {
+			return nil, nil, fmt.Errorf("translated system dependent string %d: %v", i, err)
+		}
+		if origOk && transOk {
+			orig = append(orig, origStr)
+			trans = append(trans, transStr)
+		}
+	}
+	return orig, trans, nil
+}
+
+// expandSysdepString expands the system dependent string described
+// at offset.  The string alternates between static segments, stored
+// consecutively in the file, and references to system dependent
+// segments.  The result is false if a segment cannot be expanded.
+// The segment pairs read and the bytes expanded are deducted from
+// budget, and it is an error to exceed it.
+func expandSysdepString(data []byte, order binary.ByteOrder, offset uint32, segments []*string, budget *uint64) ([]byte, bool, error) {
+	desc, ok := tableData(data, offset, 1, 4)
+	if !ok {
+		return nil, false, fmt.Errorf("descriptor out of bounds")
+	}
+	staticOffset := uint64(order.Uint32(desc))
+	var expanded []byte
+	expandable := true
+	// Each segment pair takes eight bytes of the file, so the
+	// loop ends once they run past its end.
+	for pair := uint64(offset) + 4; ; pair += 8 {
+		if pair+8 > uint64(len(data)) {
+			return nil, false, fmt.Errorf("segments out of bounds")
+		}
+		segSize := uint64(order.Uint32(data[pair:]))
+		sysdepRef := order.Uint32(data[pair+4:])
+		if staticOffset+segSize > uint64(len(data)) {
+			return nil, false, fmt.Errorf("static segment out of bounds")
+		}
+		if err := spend(budget, 8+segSize); err != nil {
+			return nil, false, err
+		}
+		expanded = append(expanded, data[staticOffset:staticOffset+segSize]...)
+		staticOffset += segSize
+		if sysdepRef == segmentsEnd {
+			break
+		}
+		if uint64(sysdepRef) >= uint64(len(segments)) {
+			return nil, false, fmt.Errorf("reference to unknown segment %d", sysdepRef)
+		}
+		if segments[sysdepRef] == nil {
+			expandable = false
+		} else {
+			if err := spend(budget, uint64(len(*segments[sysdepRef]))); err != nil {
+				return nil, false, err
+			}
+			expanded = append(expanded, *segments[sysdepRef]...)
+		}
+	}
+	// The last static segment includes the trailing NUL
+	if len(expanded) != 0 && expanded[len(expanded)-1] == 0 {
+		expanded = expanded[:len(expanded)-1]
+	}
+	return expanded, expandable, nil
+}
+
+// spend deducts n from budget, returning an error if it is exceeded.
+func spend(budget *uint64, n uint64) error {
+	if n > *budget {
+		return fmt.Errorf("system dependent strings expand to more than %d times the file size", maxSysdepExpansion)
+	}
+	*budget -= n
+	return nil
+}
@@ -0,0 +1,196 @@
+package gettext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// sysdepPart is a static segment of a system dependent string,
+// followed by a reference to a system dependent segment.
+type sysdepPart struct {
+	static string
+	ref    uint32
+}
+
+// sysdepMO lays out a little endian revision 1 mo file holding a
+// header entry and the given system dependent strings, each a pair
+// of original and translated string.
+func sysdepMO(headerEntry string, segments []string, strs [][2][]sysdepPart) []byte {
+	descSize := 0
+	for _, pair := range strs {
+		for _, parts := range pair {
+			descSize += 4 + 8*len(parts)
+		}
+	}
+	tabOffset := uint32(48)
+	segOffset := tabOffset + 16
+	sysdepTabOffset := segOffset + 8*uint32(len(segments))
+	descOffset := sysdepTabOffset + 8*uint32(len(strs))
+	dataOffset := descOffset + uint32(descSize)
+
+	var data []byte
+	addData := func(s string) uint32 {
+		offset := dataOffset + uint32(len(data))
+		data = append(data, s...)
+		return offset
+	}
+	words := []uint32{le_magic, 1 << 16, 1, tabOffset, tabOffset + 8, 0, 0,
+		uint32(len(segments)), segOffset, uint32(len(strs)), sysdepTabOffset, sysdepTabOffset + 4*uint32(len(strs))}
+	words = append(words, 0, addData(""+"\x00"))
+	words = append(words, uint32(len(headerEntry)), addData(headerEntry+"\x00"))
+	for _, seg := range segments {
+		words = append(words, uint32(len(seg)+1), addData(seg+"\x00"))
+	}
+	var descs []uint32
+	var tabs [2][]uint32
+	offset := descOffset
+	for side := 0; side < 2; side++ {
+		for _, pair := range strs {
+			parts := pair[side]
+			tabs[side] = append(tabs[side], offset)
+			offset += 4 + 8*uint32(len(parts))
+			var static string
+			for _, part := range parts {
+				static += part.static
+			}
+			descs = append(descs, addData(static))
+			for _, part := range parts {
+				descs = append(descs, uint32(len(part.static)), part.ref)
+			}
+		}
+	}
+	words = append(words, tabs[0]...)
+	words = append(words, tabs[1]...)
+	words = append(words, descs...)
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, words)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestSysdepStrings(t *testing.T) {
+	header := "Content-Type: text/plain; charset=UTF-8\nPlural-Forms: nplurals=2; plural=n != 1;\n"
+	data := sysdepMO(header, []string{"PRIu64", "PRIx32", "PRIq64", "I"}, [][2][]sysdepPart{{
+		// "%<PRIu64> file", "%<PRIu64> files"
+		{{"%", 0}, {" file\x00%", 0}, {" files\x00", segmentsEnd}},
+		{{"%", 0}, {" Datei\x00%", 0}, {" Dateien\x00", segmentsEnd}},
+	}, {
+		// Context "addr", "%<PRIx32>"
+		{{"addr\x04%", 1}, {"\x00", segmentsEnd}},
+		{{"0x%", 1}, {"\x00", segmentsEnd}},
+	}, {
+		// An unknown macro
+		{{"%", 2}, {" bytes\x00", segmentsEnd}},
+		{{"%", 2}, {" Bytes\x00", segmentsEnd}},
+	}, {
+		// The glibc "I" flag
+		{{"%", 3}, {"d items\x00", segmentsEnd}},
+		{{"%", 3}, {"d Elemente\x00", segmentsEnd}},
+	}})
+
+	catalog, err := ParseMOData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, fmt.Sprintf(catalog.NGettext("%d file", "%d files", 1), 1), "1 Datei")
+	assert_equal(t, fmt.Sprintf(catalog.NGettext("%d file", "%d files", 3), 3), "3 Dateien")
+	assert_equal(t, fmt.Sprintf(catalog.PGettext("addr", "%x"), 255), "0xff")
+	assert_equal(t, catalog.Gettext("%d items"), "%d Elemente")
+	// Messages using unknown macros are skipped
+	assert_equal(t, catalog.Gettext("% bytes"), "% bytes")
+
+	messages, err := ReadMO(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, len(messages), 4)
+	assertDeepEqual(t, messages[1], Message{
+		ID:       "%d file",
+		IDPlural: "%d files",
+		Str:      []string{"%d Datei", "%d Dateien"},
+	})
+	assertDeepEqual(t, messages[2], Message{
+		Context:    "addr",
+		HasContext: true,
+		ID:         "%x",
+		Str:        []string{"0x%x"},
+	})
+}
+
+func TestSysdepStringsErrors(t *testing.T) {
+	for _, test := range []struct {
+		strs [][2][]sysdepPart
+		err  string
+	}{
+		{[][2][]sysdepPart{{{{"%", 5}, {"\x00", segmentsEnd}}, {{"\x00", segmentsEnd}}}}, "original system dependent string 0: reference to unknown segment 5"},
+		// Without an end marker, the string data is read as
+		// segment pairs
+		{[][2][]sysdepPart{{{{"\x00", segmentsEnd}}, {{"%", 0}}}}, "translated system dependent string 0: static segment out of bounds"},
+	} {
+		data := sysdepMO("", []string{"PRId64"}, test.strs)
+		_, err := ParseMOData(data)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("expected error %q, got %v", test.err, err)
+		}
+	}
+
+	// The system dependent header fields must be present
+	data := moHeader(0, 28, 28, 0, 0)
+	binary.LittleEndian.PutUint32(data[4:], 1<<16)
+	if _, err := ParseMOData(data); err == nil || err.Error() != "message catalogue is too short" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSysdepStringsExpansionLimit(t *testing.T) {
+	// Every system dependent string refers to the same
+	// descriptor, whose static segment is the whole file
+	const numStrings = 8000
+	const size = 40000
+	tabOffset := uint32(48)
+	descOffset := tabOffset + 4*numStrings
+	words := []uint32{le_magic, 1 << 16, 0, tabOffset, tabOffset, 0, 0,
+		0, tabOffset, numStrings, tabOffset, tabOffset}
+	for i := 0; i < numStrings; i++ {
+		words = append(words, descOffset)
+	}
+	words = append(words, 0, size, segmentsEnd)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, words)
+	data := make([]byte, size)
+	copy(data, buf.Bytes())
+
+	_, err := ParseMOData(data)
+	if expected := "translated system dependent string 1: system dependent strings expand to more than 4 times the file size"; err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestSysdepSegmentValue(t *testing.T) {
+	for name, expected := range map[string]string{
+		"PRId8":       "d",
+		"PRIi16":      "d",
+		"PRIu32":      "d",
+		"PRIu64":      "d",
+		"PRIdLEAST32": "d",
+		"PRIuFAST16":  "d",
+		"PRIdMAX":     "d",
+		"PRIxPTR":     "x",
+		"PRIX64":      "X",
+		"PRIo32":      "o",
+		"I":           "",
+	} {
+		value, ok := sysdepSegmentValue(name)
+		if !ok || value != expected {
+			t.Errorf("%s: expected %q, got %q, %v", name, expected, value, ok)
+		}
+	}
+	for _, name := range []string{"", "PRI", "PRId", "PRIf64", "PRId128", "PRIdLEASTFAST8", "PRIdLEASTMAX", "SCNd64", "II"} {
+		if _, ok := sysdepSegmentValue(name); ok {
+			t.Errorf("%q: expected no value", name)
+		}
+	}
+}
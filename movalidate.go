@@ -0,0 +1,308 @@
+package gettext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// MOProblemKind identifies the kind of a problem found in a mo file.
+type MOProblemKind int
+
+const (
+	// MOInvalidFileHeader is a file too short to hold a header,
+	// or with an unknown magic number or version.
+	MOInvalidFileHeader MOProblemKind = iota
+	// MOTableOutOfBounds is a string or hash table extending
+	// past the end of the file.
+	MOTableOutOfBounds
+	// MOStringOutOfBounds is string data extending past the end
+	// of the file.
+	MOStringOutOfBounds
+	// MOUnterminatedString is a string not followed by a NUL
+	// byte, as libintl expects.
+	MOUnterminatedString
+	// MOUnsortedEntries is an entry whose original string sorts
+	// before that of the previous entry, which breaks lookups
+	// by binary search.
+	MOUnsortedEntries
+	// MODuplicateEntry is an entry with the same original string
+	// as the previous entry.
+	MODuplicateEntry
+	// MOMisplacedHeaderEntry is a header entry other than the
+	// first entry.
+	MOMisplacedHeaderEntry
+	// MOInvalidHashBucket is a hash table bucket referring to an
+	// entry that does not exist.
+	MOInvalidHashBucket
+	// MOFullHashTable is a hash table with no empty bucket.
+	MOFullHashTable
+	// MOUnreachableEntry is an entry that cannot be found through
+	// the hash table.
+	MOUnreachableEntry
+	// MOInvalidHeaderEntry is a header entry with a malformed
+	// field, such as an invalid Plural-Forms expression.
+	MOInvalidHeaderEntry
+	// MOInvalidPluralEntry is a plural entry whose number of
+	// translations does not match the Plural-Forms header.
+	MOInvalidPluralEntry
+	// MOInvalidSysdepStrings is a malformed table of system
+	// dependent strings in a major revision 1 file.
+	MOInvalidSysdepStrings
+)
+
+var moProblemKindNames = [...]string{
+	MOInvalidFileHeader:    "invalid-file-header",
+	MOTableOutOfBounds:     "table-out-of-bounds",
+	MOStringOutOfBounds:    "string-out-of-bounds",
+	MOUnterminatedString:   "unterminated-string",
+	MOUnsortedEntries:      "unsorted-entries",
+	MODuplicateEntry:       "duplicate-entry",
+	MOMisplacedHeaderEntry: "misplaced-header-entry",
+	MOInvalidHashBucket:    "invalid-hash-bucket",
+	MOFullHashTable:        "full-hash-table",
+	MOUnreachableEntry:     "unreachable-entry",
+	MOInvalidHeaderEntry:   "invalid-header-entry",
+	MOInvalidPluralEntry:   "invalid-plural-entry",
+	MOInvalidSysdepStrings: "invalid-sysdep-strings",
+}
+
+func (k MOProblemKind) String() string {
+	if k >= 0 && int(k) < len(moProblemKindNames) {
+		return moProblemKindNames[k]
+	}
+	return fmt.Sprintf("MOProblemKind(%d)", int(k))
+}
+
+// MOProblem describes a problem found in a mo file by ValidateMO.
+type MOProblem struct {
+	Kind MOProblemKind
+	// Offset is the position in the file of the data at fault,
+	// such as a string table entry or hash bucket.
+	Offset int64
+	// Entry is the index of the entry at fault, or -1 if the
+	// problem does not concern a single entry.
+	Entry int
+	// Msg describes the problem.
+	Msg string
+}
+
+func (p MOProblem) String() string {
+	if p.Entry >= 0 {
+		return fmt.Sprintf("offset %#x: entry %d: %s: %s", p.Offset, p.Entry, p.Kind, p.Msg)
+	}
+	return fmt.Sprintf("offset %#x: %s: %s", p.Offset, p.Kind, p.Msg)
+}
+
+// ValidationError is returned by ParseMOStrict for a mo file with
+// problems.
+type ValidationError struct {
+	Problems []MOProblem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid mo file: " + e.Problems[0].String()
+	}
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.String()
+	}
+	return fmt.Sprintf("invalid mo file: %d problems:\n%s", len(e.Problems), strings.Join(msgs, "\n"))
+}
+
+// ValidateMO checks a mo file more thoroughly than ParseMO, returning
+// all the problems found.  Besides the bounds checks done by ParseMO,
+// it checks that strings are NUL terminated, that entries are sorted
+// with the header entry first, and that every entry can be found
+// through the hash table.  A file with no problems can be used by
+// libintl as well as this package.
+func ValidateMO(data []byte) []MOProblem {
+	v := moValidator{data: data}
+	v.validate()
+	return v.problems
+}
+
+// ParseMOStrict parses a mo file held in memory into a Catalog, as
+// ParseMOData does, but returns a *ValidationError listing the
+// problems of a file that fails ValidateMO.
+func ParseMOStrict(data []byte) (Catalog, error) {
+	if problems := ValidateMO(data); len(problems) != 0 {
+		return Catalog{}, &ValidationError{Problems: problems}
+	}
+	return ParseMOData(data)
+}
+
+type moValidator struct {
+	data     []byte
+	order    binary.ByteOrder
+	header   header
+	problems []MOProblem
+}
+
+func (v *moValidator) problem(kind MOProblemKind, offset uint64, entry int, format string, args ...interface{}) {
+	v.problems = append(v.problems, MOProblem{
+		Kind:   kind,
+		Offset: int64(offset),
+		Entry:  entry,
+		Msg:    fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *moValidator) validate() {
+	if !v.validateFileHeader() {
+		return
+	}
+	h := &v.header
+	origTab, origOk := tableData(v.data, h.OrigTabOffset, h.NumStrings, 8)
+	if !origOk {
+		v.problem(MOTableOutOfBounds, 12, -1, "original strings table out of bounds")
+	}
+	transTab, transOk := tableData(v.data, h.TransTabOffset, h.NumStrings, 8)
+	if !transOk {
+		v.problem(MOTableOutOfBounds, 16, -1, "translated strings table out of bounds")
+	}
+	var hashTab []byte
+	hashOk := true
+	if h.HashTabSize > 2 {
+		hashTab, hashOk = tableData(v.data, h.HashTabOffset, h.HashTabSize, 4)
+		if !hashOk {
+			v.problem(MOTableOutOfBounds, 20, -1, "hash table out of bounds")
+		}
+	}
+	if !origOk || !transOk {
+		return
+	}
+	stringsOk := v.validateStrings(origTab, h.OrigTabOffset, "original")
+	stringsOk = v.validateStrings(transTab, h.TransTabOffset, "translated") && stringsOk
+	if !stringsOk {
+		return
+	}
+
+	catalog := &mocatalog{
+		m:          &fileMapping{data: v.data},
+		order:      v.order,
+		numStrings: int(h.NumStrings),
+		origTab:    origTab,
+		transTab:   transTab,
+	}
+	if v.header.get_major_version() == 1 {
+		if _, _, err := readSysdepStrings(v.data, v.order); err != nil {
+			v.problem(MOInvalidSysdepStrings, 28, -1, "%v", err)
+		}
+	}
+	v.validateOrder(catalog)
+	if hashOk && hashTab != nil {
+		catalog.hashTab = hashTab
+		v.validateHashTable(catalog)
+	}
+	v.validateContent(catalog)
+}
+
+func (v *moValidator) validateFileHeader() bool {
+	if len(v.data) < binary.Size(&v.header) {
+		v.problem(MOInvalidFileHeader, 0, -1, "message catalogue is too short")
+		return false
+	}
+	v.order = binary.LittleEndian
+	switch magic := v.order.Uint32(v.data); magic {
+	case le_magic:
+	case be_magic:
+		v.order = binary.BigEndian
+	default:
+		v.problem(MOInvalidFileHeader, 0, -1, "wrong magic number %#x", magic)
+		return false
+	}
+	binary.Read(bytes.NewReader(v.data), v.order, &v.header)
+	if major := v.header.get_major_version(); major != 0 && major != 1 {
+		v.problem(MOInvalidFileHeader, 4, -1, "unsupported version %d.%d", major, v.header.get_minor_version())
+		return false
+	}
+	return true
+}
+
+// validateStrings checks the strings referred to by a string table,
+// returning false if any lie out of bounds.
+func (v *moValidator) validateStrings(table []byte, tableOffset uint32, name string) bool {
+	ok := true
+	for i := 0; i < len(table)/8; i++ {
+		entryOffset := uint64(tableOffset) + 8*uint64(i)
+		strLen := v.order.Uint32(table[8*i:])
+		strOffset := v.order.Uint32(table[8*i+4:])
+		if _, inBounds := tableData(v.data, strOffset, strLen, 1); !inBounds {
+			v.problem(MOStringOutOfBounds, entryOffset, i, "%s string data (len=%x, offset=%x) is out of bounds", name, strLen, strOffset)
+			ok = false
+			continue
+		}
+		end := uint64(strOffset) + uint64(strLen)
+		if end >= uint64(len(v.data)) || v.data[end] != 0 {
+			v.problem(MOUnterminatedString, end, i, "%s string is not NUL terminated", name)
+		}
+	}
+	return ok
+}
+
+func (v *moValidator) validateOrder(catalog *mocatalog) {
+	origTabOffset := uint64(v.header.OrigTabOffset)
+	for i := 1; i < catalog.numStrings; i++ {
+		prev := catalog.msgID(i - 1)
+		msgid := catalog.msgID(i)
+		switch cmp := bytes.Compare(prev, msgid); {
+		case len(msgid) == 0:
+			v.problem(MOMisplacedHeaderEntry, origTabOffset+8*uint64(i), i, "header entry is not the first entry")
+		case cmp > 0:
+			v.problem(MOUnsortedEntries, origTabOffset+8*uint64(i), i, "%q sorts before the previous entry %q", msgid, prev)
+		case cmp == 0:
+			v.problem(MODuplicateEntry, origTabOffset+8*uint64(i), i, "duplicate entry %q", msgid)
+		}
+	}
+}
+
+func (v *moValidator) validateHashTable(catalog *mocatalog) {
+	hashTabOffset := uint64(v.header.HashTabOffset)
+	hasEmpty := false
+	bucketsOk := true
+	for i := 0; i < len(catalog.hashTab)/4; i++ {
+		strIndex := v.order.Uint32(catalog.hashTab[4*i:])
+		if strIndex == 0 {
+			hasEmpty = true
+		} else if uint64(strIndex) > uint64(catalog.numStrings) {
+			v.problem(MOInvalidHashBucket, hashTabOffset+4*uint64(i), -1, "hash bucket %d refers to entry %d of %d", i, strIndex-1, catalog.numStrings)
+			bucketsOk = false
+		}
+	}
+	if !hasEmpty {
+		v.problem(MOFullHashTable, hashTabOffset, -1, "hash table has no empty bucket")
+	}
+	if !bucketsOk {
+		return
+	}
+	for i := 0; i < catalog.numStrings; i++ {
+		msgid := catalog.msgID(i)
+		if _, ok, _ := catalog.hashIndex(string(msgid)); !ok {
+			v.problem(MOUnreachableEntry, uint64(v.header.OrigTabOffset)+8*uint64(i), i, "%q cannot be found through the hash table", msgid)
+		}
+	}
+}
+
+// validateContent checks the header entry and the number of forms of
+// plural entries, as ParseMO does.
+func (v *moValidator) validateContent(catalog *mocatalog) {
+	if catalog.numStrings > 0 && len(catalog.msgID(0)) == 0 {
+		if err := catalog.read_info(string(catalog.msgStr(0, 0))); err != nil {
+			v.problem(MOInvalidHeaderEntry, uint64(v.header.TransTabOffset), 0, "%v", err)
+			return
+		}
+	}
+	for i := 0; i < catalog.numStrings; i++ {
+		orig := catalog.tableString(catalog.origTab, i)
+		if bytes.IndexByte(orig, '\x00') < 0 || isOrdinalKey(string(catalog.msgID(i))) {
+			continue
+		}
+		numStrs := bytes.Count(catalog.tableString(catalog.transTab, i), []byte{0}) + 1
+		if err := catalog.checkPluralEntry(string(catalog.msgID(i)), numStrs); err != nil {
+			v.problem(MOInvalidPluralEntry, uint64(v.header.TransTabOffset)+8*uint64(i), i, "%v", err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package gettext
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// rawMO lays out a little endian mo file without a hash table,
+// storing the strings as given.
+func rawMO(orig, trans []string) []byte {
+	n := uint32(len(orig))
+	var words []uint32
+	offset := 28 + 16*n
+	var data []byte
+	for _, table := range [][]string{orig, trans} {
+		for _, s := range table {
+			words = append(words, uint32(len(s)), offset+uint32(len(data)))
+			data = append(append(data, s...), 0)
+		}
+	}
+	return append(moHeader(n, 28, 28+8*n, 0, 0, words...), data...)
+}
+
+func problemKinds(problems []MOProblem) []MOProblemKind {
+	kinds := []MOProblemKind{}
+	for _, p := range problems {
+		kinds = append(kinds, p.Kind)
+	}
+	return kinds
+}
+
+func TestValidateMOTestdata(t *testing.T) {
+	files, err := filepath.Glob("testdata/*/*.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if problems := ValidateMO(data); len(problems) != 0 {
+			t.Errorf("%s: unexpected problems: %v", file, problems)
+		}
+		if _, err := ParseMOStrict(data); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestValidateMO(t *testing.T) {
+	header := "Content-Type: text/plain; charset=UTF-8\n"
+	for _, test := range []struct {
+		name  string
+		data  []byte
+		kinds []MOProblemKind
+	}{{
+		name:  "valid",
+		data:  rawMO([]string{"", "a", "b"}, []string{header, "A", "B"}),
+		kinds: []MOProblemKind{},
+	}, {
+		name:  "short",
+		data:  rawMO(nil, nil)[:20],
+		kinds: []MOProblemKind{MOInvalidFileHeader},
+	}, {
+		name:  "magic",
+		data:  append([]byte{1, 2, 3, 4}, rawMO(nil, nil)[4:]...),
+		kinds: []MOProblemKind{MOInvalidFileHeader},
+	}, {
+		name:  "tables",
+		data:  moHeader(1, 0xfffffff8, 1000, 3, 2000),
+		kinds: []MOProblemKind{MOTableOutOfBounds, MOTableOutOfBounds, MOTableOutOfBounds},
+	}, {
+		name:  "string bounds",
+		data:  moHeader(1, 28, 36, 0, 0, 0xffffffff, 1, 0, 0),
+		kinds: []MOProblemKind{MOStringOutOfBounds, MOUnterminatedString},
+	}, {
+		name:  "unterminated",
+		data:  rawMO([]string{"", "a"}, []string{header, "A"})[:28+32+len(header)+1+2+1+1],
+		kinds: []MOProblemKind{MOUnterminatedString},
+	}, {
+		name:  "unsorted",
+		data:  rawMO([]string{"", "b", "a"}, []string{header, "B", "A"}),
+		kinds: []MOProblemKind{MOUnsortedEntries},
+	}, {
+		name:  "duplicate",
+		data:  rawMO([]string{"", "a", "a"}, []string{header, "A", "A"}),
+		kinds: []MOProblemKind{MODuplicateEntry},
+	}, {
+		name:  "header",
+		data:  rawMO([]string{"a", ""}, []string{"A", header}),
+		kinds: []MOProblemKind{MOMisplacedHeaderEntry},
+	}, {
+		name:  "header entry",
+		data:  rawMO([]string{""}, []string{"Plural-Forms: nplurals=2; plural=n+;\n"}),
+		kinds: []MOProblemKind{MOInvalidHeaderEntry},
+	}, {
+		name:  "plural entry",
+		data:  rawMO([]string{"", "a\x00b"}, []string{"Plural-Forms: nplurals=2; plural=n != 1;\n", "A"}),
+		kinds: []MOProblemKind{MOInvalidPluralEntry},
+	}, {
+		name:  "hash buckets",
+		data:  moHeader(1, 28, 36, 3, 44, 0, 56, 0, 56, 1, 1, 7),
+		kinds: []MOProblemKind{MOUnterminatedString, MOUnterminatedString, MOInvalidHashBucket, MOFullHashTable},
+	}, {
+		name:  "sysdep strings",
+		data:  sysdepMO(header, []string{"PRId64"}, [][2][]sysdepPart{{{{"%", 5}, {"\x00", segmentsEnd}}, {{"\x00", segmentsEnd}}}}),
+		kinds: []MOProblemKind{MOInvalidSysdepStrings},
+	}} {
+		problems := ValidateMO(test.data)
+		if kinds := problemKinds(problems); !reflect.DeepEqual(kinds, test.kinds) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.kinds, problems)
+		}
+	}
+}
+
+func TestValidateMOHashTable(t *testing.T) {
+	data, hashOffset, hashSize := encodeTestMO(t, "testdata/en/messages.po")
+	table := data[hashOffset : hashOffset+4*hashSize]
+	rotated := append(append([]byte{}, table[4:]...), table[:4]...)
+	copy(table, rotated)
+	problems := ValidateMO(data)
+	if len(problems) == 0 {
+		t.Fatal("expected problems")
+	}
+	for _, p := range problems {
+		assertDeepEqual(t, p.Kind, MOUnreachableEntry)
+		if p.Entry < 0 || p.Offset != int64(28+8*p.Entry) {
+			t.Errorf("unexpected location of %v", p)
+		}
+	}
+
+	for i := uint32(0); i < hashSize; i++ {
+		binary.LittleEndian.PutUint32(table[4*i:], 1)
+	}
+	problems = ValidateMO(data)
+	assertDeepEqual(t, problems[0].Kind, MOFullHashTable)
+	assertDeepEqual(t, problems[0].Offset, int64(hashOffset))
+}
+
+func TestParseMOStrict(t *testing.T) {
+	data := rawMO([]string{"", "b", "a"}, []string{"", "B", "A"})
+	// The file is usable, if not by binary search
+	if _, err := ParseMOData(data); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ParseMOStrict(data)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	assertDeepEqual(t, validationErr.Problems, []MOProblem{{
+		Kind:   MOUnsortedEntries,
+		Offset: 44,
+		Entry:  2,
+		Msg:    `"a" sorts before the previous entry "b"`,
+	}})
+	assert_equal(t, err.Error(), `invalid mo file: offset 0x2c: entry 2: unsorted-entries: "a" sorts before the previous entry "b"`)
+}
@@ -0,0 +1,193 @@
+package gettext
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MOEncoder writes message catalogs in the binary mo format, as
+// produced by GNU msgfmt.
+type MOEncoder struct {
+	// ByteOrder is the byte order of the written file.  If it is
+	// nil, little endian byte order is used.
+	ByteOrder binary.ByteOrder
+	// NoHash omits the hash table from the file, so lookups fall
+	// back to a binary search.
+	NoHash bool
+}
+
+// moString is a string to be written to the strings section of a mo
+// file.
+type moString struct {
+	key   string
+	orig  string
+	trans string
+}
+
+// Encode writes messages to w as a mo file.
+//
+// All messages are written, so callers wanting msgfmt's behaviour
+// should first drop fuzzy, obsolete and untranslated entries.
+func (e *MOEncoder) Encode(w io.Writer, messages []Message) error {
+	order := e.ByteOrder
+	if order == nil {
+		order = binary.LittleEndian
+	}
+
+	strs := make([]moString, 0, len(messages))
+	seen := make(map[string]bool, len(messages))
+	for i := range messages {
+		msg := &messages[i]
+		key := msg.key()
+		if seen[key] {
+			return fmt.Errorf("duplicate message %q", key)
+		}
+		seen[key] = true
+
+		orig := key
+		if msg.IDPlural != "" {
+			orig += "\x00" + msg.IDPlural
+		}
+		strs = append(strs, moString{
+			key:   key,
+			orig:  orig,
+			trans: strings.Join(msg.Str, "\x00"),
+		})
+	}
+	// Message IDs are sorted so a binary search can be used to
+	// find them.
+	sort.Slice(strs, func(i, j int) bool {
+		return strs[i].key < strs[j].key
+	})
+
+	// Offsets are computed in 64 bits, and checked before they
+	// are stored in the 32-bit fields of the file.  Sixteen bytes
+	// of string tables are needed per message, so larger catalogs
+	// cannot fit in any case.
+	numStrings := uint64(len(strs))
+	if numStrings > (1<<32-1)/16 {
+		return fmt.Errorf("message catalogue is too large")
+	}
+	var hashTabSize uint32
+	if !e.NoHash {
+		hashTabSize = hashTableSize(len(strs))
+	}
+
+	var h header
+	origTabOffset := uint64(binary.Size(&h))
+	transTabOffset := origTabOffset + 8*numStrings
+	hashTabOffset := transTabOffset + 8*numStrings
+
+	// Lay out the original strings followed by the translations,
+	// each terminated by a nul byte.
+	origTab := make([]uint32, 0, 2*len(strs))
+	transTab := make([]uint32, 0, 2*len(strs))
+	offset := hashTabOffset + 4*uint64(hashTabSize)
+	for _, s := range strs {
+		origTab = append(origTab, uint32(len(s.orig)), uint32(offset))
+		offset += uint64(len(s.orig)) + 1
+	}
+	for _, s := range strs {
+		transTab = append(transTab, uint32(len(s.trans)), uint32(offset))
+		offset += uint64(len(s.trans)) + 1
+	}
+	if offset > 1<<32-1 {
+		return fmt.Errorf("message catalogue is too large")
+	}
+	h = header{
+		Magic:          le_magic,
+		Version:        0,
+		NumStrings:     uint32(numStrings),
+		OrigTabOffset:  uint32(origTabOffset),
+		TransTabOffset: uint32(transTabOffset),
+		HashTabSize:    hashTabSize,
+		HashTabOffset:  uint32(hashTabOffset),
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := binary.Write(bw, order, &h); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, order, origTab); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, order, transTab); err != nil {
+		return err
+	}
+	if hashTabSize != 0 {
+		if err := binary.Write(bw, order, buildHashTable(strs, hashTabSize)); err != nil {
+			return err
+		}
+	}
+	for _, s := range strs {
+		bw.WriteString(s.orig)
+		bw.WriteByte(0)
+	}
+	for _, s := range strs {
+		bw.WriteString(s.trans)
+		bw.WriteByte(0)
+	}
+	return bw.Flush()
+}
+
+// hashTableSize returns the hash table size used by msgfmt for a
+// catalog of numStrings strings.
+func hashTableSize(numStrings int) uint32 {
+	size := nextPrime(uint32(numStrings) * 4 / 3)
+	if size < 3 {
+		size = 3
+	}
+	return size
+}
+
+// nextPrime returns the first odd number not less than seed that
+// passes isPrime, as msgfmt's next_prime() does.
+func nextPrime(seed uint32) uint32 {
+	seed |= 1
+	for !isPrime(seed) {
+		seed += 2
+	}
+	return seed
+}
+
+// isPrime tests an odd candidate for primality, in the same way as
+// msgfmt.  Like msgfmt, it wrongly rejects 3, so small tables are
+// sized identically.
+func isPrime(candidate uint32) bool {
+	divn := uint64(3)
+	sq := divn * divn
+	for sq < uint64(candidate) && uint64(candidate)%divn != 0 {
+		divn++
+		sq += 4 * divn
+		divn++
+	}
+	return uint64(candidate)%divn != 0
+}
+
+// buildHashTable fills a hash table using libintl's open addressing
+// scheme, mirroring the lookup in mocatalog.msgIndex.
+func buildHashTable(strs []moString, size uint32) []uint32 {
+	table := make([]uint32, size)
+	for i, s := range strs {
+		hval := hashString(s.key)
+		idx := hval % size
+		if table[idx] != 0 {
+			incr := 1 + (hval % (size - 2))
+			for table[idx] != 0 {
+				if idx >= size-incr {
+					idx -= size - incr
+				} else {
+					idx += incr
+				}
+			}
+		}
+		// Entries hold the string index incremented by one,
+		// so zero marks an empty slot.
+		table[idx] = uint32(i) + 1
+	}
+	return table
+}
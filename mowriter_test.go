@@ -0,0 +1,127 @@
+package gettext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// readPOFile reads the messages from a po file that msgfmt would
+// include in a mo file.
+func readPOFile(t *testing.T, filename string) []Message {
+	t.Helper()
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	messages, err := ReadPO(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []Message
+	for _, msg := range messages {
+		if msg.Obsolete || (!msg.IsHeader() && (msg.HasFlag("fuzzy") || !msg.IsTranslated())) {
+			continue
+		}
+		result = append(result, msg)
+	}
+	return result
+}
+
+func TestMOEncoderMatchesMsgfmt(t *testing.T) {
+	for _, test := range []struct {
+		po, mo  string
+		encoder MOEncoder
+	}{
+		{"en/messages.po", "en/messages.mo", MOEncoder{}},
+		{"en/messages.po", "en/messages-be.mo", MOEncoder{ByteOrder: binary.BigEndian}},
+		{"en/messages.po", "en/messages-nohash.mo", MOEncoder{NoHash: true}},
+		{"en-no-plural-forms/messages.po", "en-no-plural-forms/messages.mo", MOEncoder{}},
+		{"en_AU/messages.po", "en_AU/messages.mo", MOEncoder{}},
+		{"es/messages.po", "es/messages.mo", MOEncoder{}},
+		{"ja/messages.po", "ja/messages.mo", MOEncoder{}},
+	} {
+		test := test
+		t.Run(test.mo, func(t *testing.T) {
+			messages := readPOFile(t, "testdata/"+test.po)
+			expected, err := ioutil.ReadFile("testdata/" + test.mo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := test.encoder.Encode(&buf, messages); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), expected) {
+				t.Errorf("encoded catalog does not match %s:\n%q\n%q", test.mo, buf.Bytes(), expected)
+			}
+		})
+	}
+}
+
+func TestMOEncoderRoundTrip(t *testing.T) {
+	messages := []Message{
+		{ID: "", Str: []string{"Plural-Forms: nplurals=3; plural=n==1 ? 0 : n==2 ? 1 : 2;\n"}},
+		{ID: "zebra", Str: []string{"Zebra"}},
+		{ID: "apple", Str: []string{"Apfel"}},
+		{Context: "fruit", ID: "orange", Str: []string{"Orange"}},
+		{Context: "colour", ID: "orange", Str: []string{"orange"}},
+		{HasContext: true, ID: "apple", Str: []string{"Apfel (leerer Kontext)"}},
+		{ID: "%d item", IDPlural: "%d items", Str: []string{"one", "two", "many"}},
+	}
+	// Add enough messages to cause hash collisions
+	for i := 0; i < 100; i++ {
+		id := string(rune('a'+i%26)) + string(rune('a'+i/26))
+		messages = append(messages, Message{ID: id, Str: []string{"x" + id}})
+	}
+
+	for _, encoder := range []MOEncoder{
+		{},
+		{ByteOrder: binary.BigEndian},
+		{NoHash: true},
+	} {
+		var buf bytes.Buffer
+		if err := encoder.Encode(&buf, messages); err != nil {
+			t.Fatal(err)
+		}
+		catalog, err := ParseMOData(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert_equal(t, catalog.Gettext("apple"), "Apfel")
+		assert_equal(t, catalog.Gettext("zebra"), "Zebra")
+		assert_equal(t, catalog.PGettext("fruit", "orange"), "Orange")
+		assert_equal(t, catalog.PGettext("colour", "orange"), "orange")
+		assert_equal(t, catalog.PGettext("", "apple"), "Apfel (leerer Kontext)")
+		assert_equal(t, catalog.NGettext("%d item", "%d items", 1), "one")
+		assert_equal(t, catalog.NGettext("%d item", "%d items", 2), "two")
+		assert_equal(t, catalog.NGettext("%d item", "%d items", 5), "many")
+		for _, msg := range messages[7:] {
+			assert_equal(t, catalog.Gettext(msg.ID), msg.Str[0])
+		}
+		assert_equal(t, catalog.Gettext("missing"), "missing")
+	}
+}
+
+func TestMOEncoderDuplicate(t *testing.T) {
+	var encoder MOEncoder
+	err := encoder.Encode(ioutil.Discard, []Message{
+		{ID: "a", Str: []string{"b"}},
+		{ID: "a", Str: []string{"c"}},
+	})
+	if err == nil {
+		t.Fatal("expected error for duplicate messages")
+	}
+	assert_equal(t, err.Error(), `duplicate message "a"`)
+}
+
+func TestHashTableSize(t *testing.T) {
+	assertDeepEqual(t, hashTableSize(0), uint32(3))
+	assertDeepEqual(t, hashTableSize(3), uint32(5))
+	assertDeepEqual(t, hashTableSize(5), uint32(7))
+	assertDeepEqual(t, hashTableSize(100), uint32(137))
+}
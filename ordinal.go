@@ -0,0 +1,43 @@
+package gettext
+
+import (
+	"strings"
+)
+
+// OrdinalContext is the message context (msgctxt) of ordinal messages.
+//
+// Gettext has no notion of ordinal numbers, so ordinal messages are
+// stored as plural messages with this context, or with the context
+// OrdinalContext + ":" + msgctxt for messages that have a context of
+// their own.  The msgid_plural repeats the msgid, and there is one
+// translation for each of the language's CLDR ordinal plural
+// categories, in CLDR order (zero, one, two, few, many, other):
+//
+//	msgctxt "@ordinal"
+//	msgid "%d."
+//	msgid_plural "%d."
+//	msgstr[0] "%dst"
+//	msgstr[1] "%dnd"
+//	msgstr[2] "%drd"
+//	msgstr[3] "%dth"
+const OrdinalContext = "@ordinal"
+
+// ordinalContext returns the context of an ordinal message with the
+// given context.
+func ordinalContext(msgctxt string) string {
+	if msgctxt == "" {
+		return OrdinalContext
+	}
+	return OrdinalContext + ":" + msgctxt
+}
+
+func isOrdinalContext(msgctxt string) bool {
+	return msgctxt == OrdinalContext || strings.HasPrefix(msgctxt, OrdinalContext+":")
+}
+
+// isOrdinalKey returns true if a catalog key is that of an ordinal
+// message.
+func isOrdinalKey(key string) bool {
+	pos := strings.IndexByte(key, '\x04')
+	return pos >= 0 && isOrdinalContext(key[:pos])
+}
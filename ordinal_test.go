@@ -0,0 +1,90 @@
+package gettext
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const ordinalPO = `msgid ""
+msgstr ""
+"Language: en\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+msgctxt "@ordinal"
+msgid "%d."
+msgid_plural "%d."
+msgstr[0] "%dst"
+msgstr[1] "%dnd"
+msgstr[2] "%drd"
+msgstr[3] "%dth"
+
+msgctxt "@ordinal:race"
+msgid "%d. place"
+msgid_plural "%d. place"
+msgstr[0] "%dst place"
+msgstr[1] "%dnd place"
+msgstr[2] "%drd place"
+msgstr[3] "%dth place"
+`
+
+func TestOrdinal(t *testing.T) {
+	po, err := ParsePO(strings.NewReader(ordinalPO))
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := ReadPO(strings.NewReader(ordinalPO))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := (&MOEncoder{}).Encode(&buf, messages); err != nil {
+		t.Fatal(err)
+	}
+	mo, err := ParseMOData(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, catalog := range []Catalog{po, mo} {
+		for n, expected := range map[uint32]string{
+			1:   "1st",
+			2:   "2nd",
+			3:   "3rd",
+			4:   "4th",
+			11:  "11th",
+			12:  "12th",
+			13:  "13th",
+			21:  "21st",
+			102: "102nd",
+		} {
+			assert_equal(t, fmt.Sprintf(catalog.Ordinal("%d.", n), n), expected)
+		}
+		assert_equal(t, catalog.POrdinal("race", "%d. place", 3), "%drd place")
+		// Ordinal messages are separate from the plain message
+		assert_equal(t, catalog.Gettext("%d."), "%d.")
+		// Untranslated messages are returned as is
+		assert_equal(t, catalog.Ordinal("%d. time", 1), "%d. time")
+		assert_equal(t, catalog.POrdinal("lap", "%d.", 1), "%d.")
+	}
+}
+
+func TestOrdinalLanguageFromLocale(t *testing.T) {
+	// Without a Language header, the ordinal rules of the locale
+	// are used
+	data := strings.Replace(ordinalPO, `"Language: en\n"`+"\n", "", 1)
+	translations := &TextDomain{Name: "messages", FS: fstest.MapFS{
+		"en_GB/LC_MESSAGES/messages.po": &fstest.MapFile{Data: []byte(data)},
+	}}
+	catalog := translations.Locale("en_GB")
+	assert_equal(t, catalog.Ordinal("%d.", 22), "%dnd")
+
+	// If the language is unknown, the last form is used
+	catalog, err := ParsePO(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Ordinal("%d.", 22), "%dth")
+}
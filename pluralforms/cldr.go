@@ -0,0 +1,438 @@
+package pluralforms
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Category is a CLDR plural category.
+type Category int
+
+const (
+	Zero Category = iota
+	One
+	Two
+	Few
+	Many
+	Other
+)
+
+var categoryNames = [...]string{
+	Zero:  "zero",
+	One:   "one",
+	Two:   "two",
+	Few:   "few",
+	Many:  "many",
+	Other: "other",
+}
+
+func (c Category) String() string {
+	if c < Zero || c > Other {
+		return fmt.Sprintf("Category(%d)", int(c))
+	}
+	return categoryNames[c]
+}
+
+// ParseCategory returns the category with the given CLDR name.
+func ParseCategory(name string) (Category, error) {
+	for c, categoryName := range categoryNames {
+		if name == categoryName {
+			return Category(c), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown plural category %q", name)
+}
+
+// Operands are the operands of a number used by CLDR plural rules.
+type Operands struct {
+	// I is the integer digits of the number.
+	I uint64
+	// V is the number of visible fraction digits, with trailing
+	// zeros.
+	V int
+	// W is the number of visible fraction digits, without
+	// trailing zeros.
+	W int
+	// F is the visible fraction digits, with trailing zeros.
+	F uint64
+	// T is the visible fraction digits, without trailing zeros.
+	T uint64
+}
+
+// IntegerOperands returns the operands of an integer.
+func IntegerOperands(n uint64) Operands {
+	return Operands{I: n}
+}
+
+// ParseOperands returns the operands of a number written in decimal,
+// such as "1" or "2.50".  Trailing zeros in the fraction are
+// significant, and negative numbers use their absolute value.
+func ParseOperands(s string) (Operands, error) {
+	var ops Operands
+	digits := strings.TrimPrefix(s, "-")
+	integer, fraction := digits, ""
+	hasPoint := false
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		integer, fraction = digits[:i], digits[i+1:]
+		hasPoint = true
+	}
+	if integer == "" || (hasPoint && fraction == "") || !isDigits(integer) || !isDigits(fraction) {
+		return ops, fmt.Errorf("invalid number %q", s)
+	}
+	var err error
+	if ops.I, err = strconv.ParseUint(integer, 10, 64); err != nil {
+		return ops, fmt.Errorf("number %q out of range", s)
+	}
+	if fraction != "" {
+		if ops.F, err = strconv.ParseUint(fraction, 10, 64); err != nil {
+			return ops, fmt.Errorf("number %q has too many fraction digits", s)
+		}
+	}
+	trimmed := strings.TrimRight(fraction, "0")
+	ops.V = len(fraction)
+	ops.W = len(trimmed)
+	if trimmed != "" {
+		ops.T, _ = strconv.ParseUint(trimmed, 10, 64)
+	}
+	return ops, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// value returns the value of an operand as an integer part and
+// whether it has a non-zero fraction.  Only n can have a fraction.
+// The exponent operands c and e are always zero.
+func (ops Operands) value(operand byte) (x uint64, fraction bool) {
+	switch operand {
+	case 'n':
+		return ops.I, ops.T != 0
+	case 'i':
+		return ops.I, false
+	case 'v':
+		return uint64(ops.V), false
+	case 'w':
+		return uint64(ops.W), false
+	case 'f':
+		return ops.F, false
+	case 't':
+		return ops.T, false
+	}
+	return 0, false
+}
+
+// valueRange is a range of integers in a CLDR rule, lo..hi.  A single
+// value has lo == hi.
+type valueRange struct {
+	lo, hi uint64
+}
+
+// relation is a comparison of an operand with a list of ranges, such
+// as "i % 10 = 2..4".
+type relation struct {
+	operand byte
+	// mod is the modulus applied to the operand, or zero.
+	mod uint64
+	// within is set for the "within" operator, which also
+	// matches non-integers between the ends of a range.
+	within bool
+	negate bool
+	ranges []valueRange
+}
+
+func (r *relation) match(ops Operands) bool {
+	x, fraction := ops.value(r.operand)
+	if r.mod != 0 {
+		x %= r.mod
+	}
+	matched := false
+	for _, rng := range r.ranges {
+		if r.within {
+			matched = x >= rng.lo && (x < rng.hi || (x == rng.hi && !fraction))
+		} else {
+			matched = !fraction && x >= rng.lo && x <= rng.hi
+		}
+		if matched {
+			break
+		}
+	}
+	return matched != r.negate
+}
+
+// condition is a CLDR rule condition: a disjunction of conjunctions
+// of relations.
+type condition [][]relation
+
+func (c condition) match(ops Operands) bool {
+	for _, and := range c {
+		matched := true
+		for i := range and {
+			if !and[i].match(ops) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// cldrParser parses the condition of a CLDR plural rule, as defined
+// by Unicode TR35.
+type cldrParser struct {
+	src    string
+	tokens []cldrToken
+	pos    int
+}
+
+type cldrToken struct {
+	text string
+	pos  int
+}
+
+func (p *cldrParser) errorf(pos int, format string, args ...interface{}) error {
+	return &SyntaxError{
+		Expr:   p.src,
+		Column: pos + 1,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// tokenize splits a rule into words, numbers and punctuation.
+func (p *cldrParser) tokenize() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case c >= 'a' && c <= 'z':
+			for i < len(s) && s[i] >= 'a' && s[i] <= 'z' {
+				i++
+			}
+		case c >= '0' && c <= '9':
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "!=") || strings.HasPrefix(s[i:], ".."):
+			i += 2
+		case c == '=' || c == ',' || c == '%':
+			i++
+		default:
+			return p.errorf(i, "unexpected character %q", c)
+		}
+		p.tokens = append(p.tokens, cldrToken{s[start:i], start})
+	}
+	p.tokens = append(p.tokens, cldrToken{"", len(s)})
+	return nil
+}
+
+func (p *cldrParser) peek() cldrToken {
+	return p.tokens[p.pos]
+}
+
+func (p *cldrParser) next() cldrToken {
+	tok := p.tokens[p.pos]
+	if tok.text != "" {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if its text is one of words.
+func (p *cldrParser) accept(words ...string) bool {
+	for _, word := range words {
+		if p.peek().text == word {
+			p.next()
+			return true
+		}
+	}
+	return false
+}
+
+func (p *cldrParser) unexpected(expecting string) error {
+	tok := p.peek()
+	if tok.text == "" {
+		return p.errorf(tok.pos, "unexpected end of rule, expecting %s", expecting)
+	}
+	return p.errorf(tok.pos, "unexpected %q, expecting %s", tok.text, expecting)
+}
+
+func (p *cldrParser) parseCondition() (condition, error) {
+	var cond condition
+	for {
+		var and []relation
+		for {
+			rel, err := p.parseRelation()
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, rel)
+			if !p.accept("and") {
+				break
+			}
+		}
+		cond = append(cond, and)
+		if !p.accept("or") {
+			break
+		}
+	}
+	if p.peek().text != "" {
+		return nil, p.unexpected("'and', 'or' or end of rule")
+	}
+	return cond, nil
+}
+
+func (p *cldrParser) parseRelation() (relation, error) {
+	var rel relation
+	tok := p.peek()
+	if len(tok.text) != 1 || !strings.Contains("nivwftce", tok.text) {
+		return rel, p.unexpected("operand")
+	}
+	p.next()
+	rel.operand = tok.text[0]
+	if p.accept("mod", "%") {
+		mod, err := p.parseValue()
+		if err != nil {
+			return rel, err
+		}
+		if mod == 0 {
+			return rel, p.errorf(p.tokens[p.pos-1].pos, "modulus is zero")
+		}
+		rel.mod = mod
+	}
+
+	// Besides = and !=, the older is, in and within operators
+	// are accepted.
+	switch {
+	case p.accept("="):
+	case p.accept("!="):
+		rel.negate = true
+	case p.accept("is"):
+		rel.negate = p.accept("not")
+	default:
+		rel.negate = p.accept("not")
+		if p.accept("within") {
+			rel.within = true
+		} else if !p.accept("in") {
+			return rel, p.unexpected("'=', '!=', 'is', 'in' or 'within'")
+		}
+	}
+
+	for {
+		lo, err := p.parseValue()
+		if err != nil {
+			return rel, err
+		}
+		hi := lo
+		if p.accept("..") {
+			if hi, err = p.parseValue(); err != nil {
+				return rel, err
+			}
+		}
+		rel.ranges = append(rel.ranges, valueRange{lo, hi})
+		if !p.accept(",") {
+			break
+		}
+	}
+	return rel, nil
+}
+
+func (p *cldrParser) parseValue() (uint64, error) {
+	tok := p.peek()
+	if tok.text == "" || !isDigits(tok.text) {
+		return 0, p.unexpected("number")
+	}
+	p.next()
+	value, err := strconv.ParseUint(tok.text, 10, 64)
+	if err != nil {
+		return 0, p.errorf(tok.pos, "number %s out of range", tok.text)
+	}
+	return value, nil
+}
+
+// parseCondition parses the condition of a CLDR plural rule.  Any
+// samples introduced by @integer or @decimal are ignored.
+func parseCondition(rule string) (condition, error) {
+	if i := strings.IndexByte(rule, '@'); i >= 0 {
+		rule = rule[:i]
+	}
+	rule = strings.TrimRight(rule, " \t")
+	p := &cldrParser{src: rule}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	return p.parseCondition()
+}
+
+// CLDRRules is the set of CLDR plural rules of a language, mapping
+// numbers to plural categories.
+type CLDRRules struct {
+	// conditions holds the condition of each category, nil if
+	// the language does not use the category.  Numbers matching
+	// no condition are in the Other category.
+	conditions [Other]condition
+}
+
+// ParseCLDRRules parses the plural rules of a language, given as a
+// map from category to rule condition, such as
+//
+//	map[Category]string{One: "i = 1 and v = 0"}
+//
+// The rule for Other, if present, must be empty or only hold samples.
+func ParseCLDRRules(rules map[Category]string) (*CLDRRules, error) {
+	r := &CLDRRules{}
+	for category, rule := range rules {
+		switch {
+		case category == Other:
+			if i := strings.IndexByte(rule, '@'); i >= 0 {
+				rule = rule[:i]
+			}
+			if strings.TrimSpace(rule) != "" {
+				return nil, fmt.Errorf("rule for category other must be empty")
+			}
+		case category >= Zero && category < Other:
+			cond, err := parseCondition(rule)
+			if err != nil {
+				return nil, err
+			}
+			r.conditions[category] = cond
+		default:
+			return nil, fmt.Errorf("invalid plural category %d", int(category))
+		}
+	}
+	return r, nil
+}
+
+// Categories returns the categories used by the rules, in CLDR order.
+// Other is always included.
+func (r *CLDRRules) Categories() []Category {
+	var categories []Category
+	for category, cond := range r.conditions {
+		if cond != nil {
+			categories = append(categories, Category(category))
+		}
+	}
+	return append(categories, Other)
+}
+
+// Select returns the plural category of a number.
+func (r *CLDRRules) Select(ops Operands) Category {
+	for category, cond := range r.conditions {
+		if cond != nil && cond.match(ops) {
+			return Category(category)
+		}
+	}
+	return Other
+}
@@ -0,0 +1,239 @@
+package pluralforms
+
+// cardinalRules holds the CLDR cardinal plural rules of languages, as
+// published in the CLDR plurals.xml supplemental data.  Languages
+// with no rules other than "other" map to an empty set.
+var cardinalRules = map[string]map[Category]string{
+	"ja": {},
+	"ko": {},
+	"vi": {},
+	"zh": {},
+	"id": {},
+	"th": {},
+	"en": {One: "i = 1 and v = 0"},
+	"de": {One: "i = 1 and v = 0"},
+	"nl": {One: "i = 1 and v = 0"},
+	"sv": {One: "i = 1 and v = 0"},
+	"fi": {One: "i = 1 and v = 0"},
+	"et": {One: "i = 1 and v = 0"},
+	"da": {One: "n = 1 or t != 0 and i = 0,1"},
+	"no": {One: "n = 1"},
+	"nb": {One: "n = 1"},
+	"nn": {One: "n = 1"},
+	"fo": {One: "n = 1"},
+	"bg": {One: "n = 1"},
+	"el": {One: "n = 1"},
+	"eo": {One: "n = 1"},
+	"hu": {One: "n = 1"},
+	"tr": {One: "n = 1"},
+	"es": {
+		One:  "n = 1",
+		Many: "e = 0 and i != 0 and i % 1000000 = 0 and v = 0 or e != 0..5",
+	},
+	"it": {
+		One:  "i = 1 and v = 0",
+		Many: "e = 0 and i != 0 and i % 1000000 = 0 and v = 0 or e != 0..5",
+	},
+	"pt": {
+		One:  "i = 0..1",
+		Many: "e = 0 and i != 0 and i % 1000000 = 0 and v = 0 or e != 0..5",
+	},
+	"pt_PT": {
+		One:  "i = 1 and v = 0",
+		Many: "e = 0 and i != 0 and i % 1000000 = 0 and v = 0 or e != 0..5",
+	},
+	"fr": {
+		One:  "i = 0,1",
+		Many: "e = 0 and i != 0 and i % 1000000 = 0 and v = 0 or e != 0..5",
+	},
+	"he": {
+		One: "i = 1 and v = 0 or i = 0 and v != 0",
+		Two: "i = 2 and v = 0",
+	},
+	"lv": {
+		Zero: "n % 10 = 0 or n % 100 = 11..19 or v = 2 and f % 100 = 11..19",
+		One:  "n % 10 = 1 and n % 100 != 11 or v = 2 and f % 10 = 1 and f % 100 != 11 or v != 2 and f % 10 = 1",
+	},
+	"ga": {
+		One:  "n = 1",
+		Two:  "n = 2",
+		Few:  "n = 3..6",
+		Many: "n = 7..10",
+	},
+	"ro": {
+		One: "i = 1 and v = 0",
+		Few: "v != 0 or n = 0 or n != 1 and n % 100 = 1..19",
+	},
+	"lt": {
+		One:  "n % 10 = 1 and n % 100 != 11..19",
+		Few:  "n % 10 = 2..9 and n % 100 != 11..19",
+		Many: "f != 0",
+	},
+	"ru": {
+		One:  "v = 0 and i % 10 = 1 and i % 100 != 11",
+		Few:  "v = 0 and i % 10 = 2..4 and i % 100 != 12..14",
+		Many: "v = 0 and i % 10 = 0 or v = 0 and i % 10 = 5..9 or v = 0 and i % 100 = 11..14",
+	},
+	"uk": {
+		One:  "v = 0 and i % 10 = 1 and i % 100 != 11",
+		Few:  "v = 0 and i % 10 = 2..4 and i % 100 != 12..14",
+		Many: "v = 0 and i % 10 = 0 or v = 0 and i % 10 = 5..9 or v = 0 and i % 100 = 11..14",
+	},
+	"be": {
+		One:  "n % 10 = 1 and n % 100 != 11",
+		Few:  "n % 10 = 2..4 and n % 100 != 12..14",
+		Many: "n % 10 = 0 or n % 10 = 5..9 or n % 100 = 11..14",
+	},
+	"sr": {
+		One: "v = 0 and i % 10 = 1 and i % 100 != 11 or f % 10 = 1 and f % 100 != 11",
+		Few: "v = 0 and i % 10 = 2..4 and i % 100 != 12..14 or f % 10 = 2..4 and f % 100 != 12..14",
+	},
+	"hr": {
+		One: "v = 0 and i % 10 = 1 and i % 100 != 11 or f % 10 = 1 and f % 100 != 11",
+		Few: "v = 0 and i % 10 = 2..4 and i % 100 != 12..14 or f % 10 = 2..4 and f % 100 != 12..14",
+	},
+	"cs": {
+		One:  "i = 1 and v = 0",
+		Few:  "i = 2..4 and v = 0",
+		Many: "v != 0",
+	},
+	"sk": {
+		One:  "i = 1 and v = 0",
+		Few:  "i = 2..4 and v = 0",
+		Many: "v != 0",
+	},
+	"pl": {
+		One:  "i = 1 and v = 0",
+		Few:  "v = 0 and i % 10 = 2..4 and i % 100 != 12..14",
+		Many: "v = 0 and i != 1 and i % 10 = 0..1 or v = 0 and i % 10 = 5..9 or v = 0 and i % 100 = 12..14",
+	},
+	"sl": {
+		One: "v = 0 and i % 100 = 1",
+		Two: "v = 0 and i % 100 = 2",
+		Few: "v = 0 and i % 100 = 3..4 or v != 0",
+	},
+	"ar": {
+		Zero: "n = 0",
+		One:  "n = 1",
+		Two:  "n = 2",
+		Few:  "n % 100 = 3..10",
+		Many: "n % 100 = 11..99",
+	},
+}
+
+// CardinalRules returns the CLDR cardinal plural rules of a language.
+// Locale names are looked up as by ForLanguage.
+func CardinalRules(language string) (*CLDRRules, bool) {
+	return lookupCLDRRules(cardinalRules, language)
+}
+
+func lookupCLDRRules(table map[string]map[Category]string, language string) (*CLDRRules, bool) {
+	for _, key := range languageKeys(language) {
+		if rules, ok := table[key]; ok {
+			parsed, err := ParseCLDRRules(rules)
+			if err != nil {
+				panic("invalid built-in plural rule for " + key + ": " + err.Error())
+			}
+			return parsed, true
+		}
+	}
+	return nil, false
+}
+
+// ordinalRules holds the CLDR ordinal plural rules of languages.
+var ordinalRules = map[string]map[Category]string{
+	"ar": {},
+	"bg": {},
+	"cs": {},
+	"da": {},
+	"de": {},
+	"el": {},
+	"eo": {},
+	"es": {},
+	"et": {},
+	"fi": {},
+	"fo": {},
+	"he": {},
+	"hr": {},
+	"id": {},
+	"ja": {},
+	"ko": {},
+	"lt": {},
+	"lv": {},
+	"nb": {},
+	"nl": {},
+	"nn": {},
+	"no": {},
+	"pl": {},
+	"pt": {},
+	"ru": {},
+	"sk": {},
+	"sl": {},
+	"sr": {},
+	"th": {},
+	"tr": {},
+	"zh": {},
+	"en": {
+		One: "n % 10 = 1 and n % 100 != 11",
+		Two: "n % 10 = 2 and n % 100 != 12",
+		Few: "n % 10 = 3 and n % 100 != 13",
+	},
+	"fr":  {One: "n = 1"},
+	"ga":  {One: "n = 1"},
+	"ro":  {One: "n = 1"},
+	"vi":  {One: "n = 1"},
+	"ms":  {One: "n = 1"},
+	"hy":  {One: "n = 1"},
+	"fil": {One: "n = 1"},
+	"hu":  {One: "n = 1,5"},
+	"it":  {Many: "n = 11,8,80,800"},
+	"sv":  {One: "n % 10 = 1,2 and n % 100 != 11,12"},
+	"ne":  {One: "n = 1..4"},
+	"uk":  {Few: "n % 10 = 3 and n % 100 != 13"},
+	"be":  {Few: "n % 10 = 2,3 and n % 100 != 12,13"},
+	"kk":  {Many: "n % 10 = 6 or n % 10 = 9 or n % 10 = 0 and n != 0"},
+	"sq": {
+		One:  "n = 1",
+		Many: "n % 10 = 4 and n % 100 != 14",
+	},
+	"ca": {
+		One: "n = 1,3",
+		Two: "n = 2",
+		Few: "n = 4",
+	},
+	"mk": {
+		One:  "i % 10 = 1 and i % 100 != 11",
+		Two:  "i % 10 = 2 and i % 100 != 12",
+		Many: "i % 10 = 7,8 and i % 100 != 17,18",
+	},
+	"ka": {
+		One:  "i = 1",
+		Many: "i = 0 or i % 100 = 2..20,40,60,80",
+	},
+	"hi": {
+		One:  "n = 1",
+		Two:  "n = 2,3",
+		Few:  "n = 4",
+		Many: "n = 6",
+	},
+	"bn": {
+		One:  "n = 1,5,7,8,9,10",
+		Two:  "n = 2,3",
+		Few:  "n = 4",
+		Many: "n = 6",
+	},
+	"cy": {
+		Zero: "n = 0,7,8,9",
+		One:  "n = 1",
+		Two:  "n = 2",
+		Few:  "n = 3,4",
+		Many: "n = 5,6",
+	},
+}
+
+// OrdinalRules returns the CLDR ordinal plural rules of a language,
+// which select forms such as "1st", "2nd" and "3rd".  Locale names
+// are looked up as by ForLanguage.
+func OrdinalRules(language string) (*CLDRRules, bool) {
+	return lookupCLDRRules(ordinalRules, language)
+}
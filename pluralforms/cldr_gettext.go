@@ -0,0 +1,168 @@
+package pluralforms
+
+import (
+	"fmt"
+	"math"
+)
+
+// integerSamples returns the integers used to compare CLDR rules with
+// gettext expressions: all small numbers, and numbers close to powers
+// of ten, which catch rules such as "i % 1000000 = 0".
+func integerSamples() []uint64 {
+	samples := make([]uint64, 0, 2200)
+	for n := uint64(0); n < 2000; n++ {
+		samples = append(samples, n)
+	}
+	for p := uint64(1000); ; p *= 10 {
+		for _, d := range []uint64{1, 2, 5, 11} {
+			samples = append(samples, p-d, p+d)
+		}
+		samples = append(samples, p)
+		if p > math.MaxUint64/10 {
+			break
+		}
+	}
+	return samples
+}
+
+// integerCategories returns the categories that integers fall in, in
+// CLDR order.
+func (r *CLDRRules) integerCategories() []Category {
+	var seen [Other + 1]bool
+	for _, n := range integerSamples() {
+		seen[r.Select(IntegerOperands(n))] = true
+	}
+	var categories []Category
+	for category, ok := range seen {
+		if ok {
+			categories = append(categories, Category(category))
+		}
+	}
+	return categories
+}
+
+// Gettext converts the rules for integers to a gettext plural
+// expression.  Each category that integers fall in is given a plural
+// form, in CLDR order, so categories only used for decimal numbers
+// are left out.  The categories of each plural form are returned
+// with the expression.
+func (r *CLDRRules) Gettext() (categories []Category, expr Node) {
+	categories = r.integerCategories()
+	last := len(categories) - 1
+	expr = Constant{Value: uint64(last)}
+	for form := last - 1; form >= 0; form-- {
+		expr = Ternary{
+			Cond: r.conditions[categories[form]].gettext(),
+			Then: Constant{Value: uint64(form)},
+			Else: expr,
+		}
+	}
+	return categories, expr
+}
+
+// PluralForms returns the value of a Plural-Forms header equivalent to
+// the rules for integers.
+func (r *CLDRRules) PluralForms() string {
+	categories, expr := r.Gettext()
+	return fmt.Sprintf("nplurals=%d; plural=%s;", len(categories), expr)
+}
+
+// CheckGettext checks that a gettext plural expression is consistent
+// with the rules for integers: all integers in a CLDR category must
+// select the same plural form.  It returns the plural form selected by
+// each category that integers fall in.
+//
+// Counts beyond 32 bits are only checked if expr implements
+// Expression64.
+func (r *CLDRRules) CheckGettext(expr Expression) (map[Category]int, error) {
+	expr64, is64 := expr.(Expression64)
+	forms := make(map[Category]int)
+	first := make(map[Category]uint64)
+	for _, n := range integerSamples() {
+		var form int
+		if is64 {
+			form = expr64.Eval64(n)
+		} else if n <= math.MaxUint32 {
+			form = expr.Eval(uint32(n))
+		} else {
+			continue
+		}
+		category := r.Select(IntegerOperands(n))
+		if prev, ok := forms[category]; !ok {
+			forms[category] = form
+			first[category] = n
+		} else if form != prev {
+			return nil, fmt.Errorf("plural category %s selects form %d for n = %d, but form %d for n = %d", category, prev, first[category], form, n)
+		}
+	}
+	return forms, nil
+}
+
+// gettext converts a condition to a gettext expression, for integers.
+func (c condition) gettext() Node {
+	var or Node = Constant{Value: 0}
+	for _, relations := range c {
+		var and Node = Constant{Value: 1}
+		for i := range relations {
+			and = logical(OpAnd, and, relations[i].gettext())
+		}
+		or = logical(OpOr, or, and)
+	}
+	return or
+}
+
+func (r *relation) gettext() Node {
+	if r.operand != 'n' && r.operand != 'i' {
+		// The fraction and exponent operands are zero for
+		// integers, so the relation is constant.
+		return Constant{Value: boolValue(r.match(Operands{}))}
+	}
+	var x Node = Variable{}
+	if r.mod != 0 {
+		x = Binary{Op: OpModulo, X: x, Y: Constant{Value: r.mod}}
+	}
+	// A negated relation matches values outside all the ranges.
+	var result Node = Constant{Value: boolValue(r.negate)}
+	for _, rng := range r.ranges {
+		var match Node
+		switch {
+		case rng.lo == rng.hi && r.negate:
+			match = Binary{Op: OpNotEqual, X: x, Y: Constant{Value: rng.lo}}
+		case rng.lo == rng.hi:
+			match = Binary{Op: OpEqual, X: x, Y: Constant{Value: rng.lo}}
+		case r.negate:
+			match = Binary{
+				Op: OpOr,
+				X:  Binary{Op: OpLess, X: x, Y: Constant{Value: rng.lo}},
+				Y:  Binary{Op: OpGreater, X: x, Y: Constant{Value: rng.hi}},
+			}
+		default:
+			match = Binary{
+				Op: OpAnd,
+				X:  Binary{Op: OpGreaterEqual, X: x, Y: Constant{Value: rng.lo}},
+				Y:  Binary{Op: OpLessEqual, X: x, Y: Constant{Value: rng.hi}},
+			}
+		}
+		if r.negate {
+			result = logical(OpAnd, result, match)
+		} else {
+			result = logical(OpOr, result, match)
+		}
+	}
+	return result
+}
+
+// logical combines two boolean valued nodes with && or ||, removing
+// constant operands.
+func logical(op Operator, x, y Node) Node {
+	if c, ok := x.(Constant); ok {
+		x, y = y, c
+	}
+	if c, ok := y.(Constant); ok {
+		if (c.Value != 0) == (op == OpAnd) {
+			return x
+		}
+		return c
+	}
+	return Binary{Op: op, X: x, Y: y}
+}
@@ -0,0 +1,294 @@
+package pluralforms
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOperands(t *testing.T) {
+	for _, test := range []struct {
+		number   string
+		expected Operands
+	}{
+		{"0", Operands{}},
+		{"1", Operands{I: 1}},
+		{"-5", Operands{I: 5}},
+		{"1.0", Operands{I: 1, V: 1}},
+		{"1.50", Operands{I: 1, V: 2, W: 1, F: 50, T: 5}},
+		{"0.05", Operands{V: 2, W: 2, F: 5, T: 5}},
+		{"1.203", Operands{I: 1, V: 3, W: 3, F: 203, T: 203}},
+	} {
+		ops, err := ParseOperands(test.number)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.number, err)
+		} else if ops != test.expected {
+			t.Errorf("%q: expected %+v, got %+v", test.number, test.expected, ops)
+		}
+	}
+	for _, number := range []string{"", "-", ".5", "1.", "1e3", "1,5", "99999999999999999999", "1.123456789012345678901"} {
+		if _, err := ParseOperands(number); err == nil {
+			t.Errorf("%q: expected error", number)
+		}
+	}
+}
+
+func TestCLDRSelect(t *testing.T) {
+	for _, test := range []struct {
+		language string
+		number   string
+		expected Category
+	}{
+		{"en", "1", One},
+		{"en", "1.0", Other},
+		{"en", "2", Other},
+		{"da", "0.1", One},
+		{"da", "1.0", One},
+		{"da", "2.1", Other},
+		{"fr", "0.5", One},
+		{"fr", "1000000", Many},
+		{"fr", "1000000.0", Other},
+		{"ru", "1", One},
+		{"ru", "21", One},
+		{"ru", "11", Many},
+		{"ru", "22", Few},
+		{"ru", "1.5", Other},
+		{"pl", "12", Many},
+		{"pl", "22", Few},
+		{"pl", "0.5", Other},
+		{"lv", "0", Zero},
+		{"lv", "0.1", One},
+		{"lv", "0.11", Zero},
+		{"lv", "2.5", Other},
+		{"lt", "1.2", Many},
+		{"lt", "111", Other},
+		{"cs", "1.5", Many},
+		{"ar", "0", Zero},
+		{"ar", "103", Few},
+		{"ar", "111", Many},
+		{"ar", "100", Other},
+		{"ar", "0.5", Other},
+		{"ja", "1", Other},
+		{"sr_RS@latin", "0.1", One},
+		{"pt", "0", One},
+		{"pt_PT", "0", Other},
+		{"pt_pt", "0", Other},
+		{"PT-PT.UTF-8", "0", Other},
+	} {
+		rules, ok := CardinalRules(test.language)
+		if !ok {
+			t.Fatalf("no rules for %s", test.language)
+		}
+		ops, err := ParseOperands(test.number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if category := rules.Select(ops); category != test.expected {
+			t.Errorf("%s %s: expected %s, got %s", test.language, test.number, test.expected, category)
+		}
+	}
+}
+
+func TestCLDROldSyntax(t *testing.T) {
+	rules, err := ParseCLDRRules(map[Category]string{
+		One:   "n is 1 @integer 1",
+		Few:   "n mod 10 in 2..4 and n mod 100 not in 12..14",
+		Many:  "n within 5..6",
+		Two:   "n is not 1 and n in 2",
+		Other: " @integer 0, 5~19",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for number, expected := range map[string]Category{
+		"1":   One,
+		"2":   Two,
+		"22":  Few,
+		"12":  Other,
+		"5.5": Many,
+		"6.0": Many,
+		"6.5": Other,
+	} {
+		ops, err := ParseOperands(number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if category := rules.Select(ops); category != expected {
+			t.Errorf("%s: expected %s, got %s", number, expected, category)
+		}
+	}
+	assertCategories(t, rules.Categories(), []Category{One, Two, Few, Many, Other})
+}
+
+func TestCLDRRuleErrors(t *testing.T) {
+	for _, test := range []struct {
+		rule   string
+		column int
+		msg    string
+	}{
+		{"", 1, "unexpected end of rule, expecting operand"},
+		{"x = 1", 1, "unexpected \"x\", expecting operand"},
+		{"n = ", 4, "unexpected end of rule, expecting number"},
+		{"n % 0 = 1", 5, "modulus is zero"},
+		{"n < 1", 3, "unexpected character '<'"},
+		{"n = 1 xor n = 2", 7, "unexpected \"xor\", expecting 'and', 'or' or end of rule"},
+		{"n 1", 3, "unexpected \"1\", expecting '=', '!=', 'is', 'in' or 'within'"},
+	} {
+		_, err := ParseCLDRRules(map[Category]string{One: test.rule})
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: expected *SyntaxError, got %v", test.rule, err)
+			continue
+		}
+		if syntaxErr.Column != test.column || syntaxErr.Msg != test.msg {
+			t.Errorf("%q: unexpected error %#v", test.rule, syntaxErr)
+		}
+	}
+	if _, err := ParseCLDRRules(map[Category]string{Other: "n = 1"}); err == nil {
+		t.Errorf("expected error for rule with category other")
+	}
+}
+
+func assertCategories(t *testing.T, got, expected []Category) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected categories %v, got %v", expected, got)
+	}
+}
+
+func TestCLDRPluralForms(t *testing.T) {
+	for _, test := range []struct {
+		language   string
+		categories []Category
+		expected   string
+	}{
+		{"ja", []Category{Other}, "nplurals=1; plural=0;"},
+		{"en", []Category{One, Other}, "nplurals=2; plural=n == 1 ? 0 : 1;"},
+		{"fr", []Category{One, Many, Other}, "nplurals=3; plural=n == 0 || n == 1 ? 0 : n != 0 && n % 1000000 == 0 ? 1 : 2;"},
+		{"ru", []Category{One, Few, Many}, "nplurals=3; plural=n % 10 == 1 && n % 100 != 11 ? 0 : n % 10 >= 2 && n % 10 <= 4 && (n % 100 < 12 || n % 100 > 14) ? 1 : 2;"},
+		{"cs", []Category{One, Few, Other}, "nplurals=3; plural=n == 1 ? 0 : n >= 2 && n <= 4 ? 1 : 2;"},
+		{"ar", []Category{Zero, One, Two, Few, Many, Other}, "nplurals=6; plural=n == 0 ? 0 : n == 1 ? 1 : n == 2 ? 2 : n % 100 >= 3 && n % 100 <= 10 ? 3 : n % 100 >= 11 && n % 100 <= 99 ? 4 : 5;"},
+	} {
+		rules, ok := CardinalRules(test.language)
+		if !ok {
+			t.Fatalf("no rules for %s", test.language)
+		}
+		categories, _ := rules.Gettext()
+		assertCategories(t, categories, test.categories)
+		if s := rules.PluralForms(); s != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.language, test.expected, s)
+		}
+	}
+}
+
+func TestCLDRGettextConversion(t *testing.T) {
+	// The converted expression of each language must be
+	// consistent with its rules.
+	for language := range cardinalRules {
+		rules, _ := CardinalRules(language)
+		categories, node := rules.Gettext()
+		expr, err := Compile(node.String())
+		if err != nil {
+			t.Fatalf("%s: %s", language, err)
+		}
+		forms, err := rules.CheckGettext(expr)
+		if err != nil {
+			t.Errorf("%s: %s", language, err)
+			continue
+		}
+		for form, category := range categories {
+			if forms[category] != form {
+				t.Errorf("%s: expected category %s to select form %d, got %d", language, category, form, forms[category])
+			}
+		}
+	}
+}
+
+func TestCLDRCheckGettext(t *testing.T) {
+	// gettext's rules for Latvian and Portuguese differ from
+	// CLDR's.
+	inconsistent := map[string]string{
+		"lv": "plural category zero selects form 2 for n = 0, but form 1 for n = 10",
+		"pt": "plural category one selects form 1 for n = 0, but form 0 for n = 1",
+	}
+	for language, rule := range languageRules {
+		rules, ok := CardinalRules(language)
+		if !ok {
+			continue
+		}
+		expr, err := Compile(strings.TrimSpace(strings.SplitN(rule, "plural=", 2)[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = rules.CheckGettext(expr)
+		if expected, ok := inconsistent[language]; ok {
+			if err == nil || err.Error() != expected {
+				t.Errorf("%s: expected error %q, got %v", language, expected, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %s", language, err)
+		}
+	}
+
+	rules, _ := CardinalRules("ru")
+	expr, err := Compile(russian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forms, err := rules.CheckGettext(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(forms, map[Category]int{One: 0, Few: 1, Many: 2}) {
+		t.Errorf("unexpected forms %v", forms)
+	}
+
+	// Counts beyond 32 bits are checked
+	rules, _ = CardinalRules("en")
+	expr, err = Compile("n == 10000000000 ? 0 : n != 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rules.CheckGettext(expr)
+	if expected := "plural category other selects form 1 for n = 0, but form 0 for n = 10000000000"; err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestOrdinalRules(t *testing.T) {
+	for _, test := range []struct {
+		language string
+		n        uint64
+		expected Category
+	}{
+		{"en", 1, One},
+		{"en", 2, Two},
+		{"en", 3, Few},
+		{"en", 4, Other},
+		{"en", 11, Other},
+		{"en", 12, Other},
+		{"en", 13, Other},
+		{"en", 21, One},
+		{"en", 102, Two},
+		{"en_GB.UTF-8", 23, Few},
+		{"fr", 1, One},
+		{"fr", 2, Other},
+		{"de", 1, Other},
+		{"it", 800, Many},
+		{"sv", 22, One},
+		{"cy", 8, Zero},
+	} {
+		rules, ok := OrdinalRules(test.language)
+		if !ok {
+			t.Fatalf("no rules for %s", test.language)
+		}
+		if category := rules.Select(IntegerOperands(test.n)); category != test.expected {
+			t.Errorf("%s %d: expected %s, got %s", test.language, test.n, test.expected, category)
+		}
+	}
+	for language := range ordinalRules {
+		if _, ok := OrdinalRules(language); !ok {
+			t.Errorf("no rules for %s", language)
+		}
+	}
+}
@@ -1,427 +1,334 @@
 package pluralforms
 
 import (
-	"errors"
 	"fmt"
-	"regexp"
 	"strconv"
-	"strings"
 )
 
-type match struct {
-	open_pos  int
-	close_pos int
-}
-
-var pat = regexp.MustCompile(`(\?|:|\|\||&&|==|!=|>=|>|<=|<|%|\d+|n)`)
-
-type expr_token interface {
-	compile(tokens []string) (expr Expression, err error)
-}
+// SyntaxError is returned by Compile when a plural expression cannot
+// be parsed.
+type SyntaxError struct {
+	// Expr is the expression being compiled.
+	Expr string
+	// Column is the 1-based byte offset in Expr where the error
+	// was found.
+	Column int
+	// Msg describes the error.
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("plural expression %q: column %d: %s", e.Expr, e.Column, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokVariable
+	tokNumber
+	tokOperator
+	tokNot
+	tokQuestion
+	tokColon
+	tokLeftParen
+	tokRightParen
+)
 
-type test_token interface {
-	compile(tokens []string) (test test, err error)
+type token struct {
+	kind tokenKind
+	pos  int
+	text string
+	// value holds the value of a number token.
+	value uint64
+	// op holds the operator of an operator token.
+	op Operator
+}
+
+// twoCharOperators and oneCharOperators map the binary operators to
+// their text.  Two character operators must be matched first.
+var twoCharOperators = map[string]Operator{
+	"||": OpOr,
+	"&&": OpAnd,
+	"==": OpEqual,
+	"!=": OpNotEqual,
+	"<=": OpLessEqual,
+	">=": OpGreaterEqual,
+}
+
+var oneCharOperators = map[byte]Operator{
+	'<': OpLess,
+	'>': OpGreater,
+	'+': OpAdd,
+	'-': OpSubtract,
+	'*': OpMultiply,
+	'/': OpDivide,
+	'%': OpModulo,
+}
+
+// lexer splits a plural expression into tokens.
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) error {
+	return &SyntaxError{
+		Expr:   l.src,
+		Column: pos + 1,
+		Msg:    fmt.Sprintf(format, args...),
+	}
 }
 
-type cmp_test_builder func(val uint32, flipped bool) test
-type logic_test_build func(left test, right test) test
-
-var ternary_token ternary_
+// next returns the next token.  As in libintl, a semicolon or newline
+// ends the expression, so the rest of a Plural-Forms header field is
+// ignored.
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && (l.src[l.pos] == ' ' || l.src[l.pos] == '\t') {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.src) || l.src[l.pos] == ';' || l.src[l.pos] == '\n' {
+		return token{kind: tokEOF, pos: start}, nil
+	}
 
-type ternary_ struct{}
+	c := l.src[l.pos]
+	switch {
+	case c >= '0' && c <= '9':
+		for l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+			l.pos++
+		}
+		text := l.src[start:l.pos]
+		value, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return token{}, l.errorf(start, "number %s out of range", text)
+		}
+		return token{kind: tokNumber, pos: start, text: text, value: value}, nil
+	case c == 'n':
+		l.pos++
+		return token{kind: tokVariable, pos: start, text: "n"}, nil
+	}
 
-func (ternary_) compile(tokens []string) (expr Expression, err error) {
-	main, err := split_tokens(tokens, "?")
-	if err != nil {
-		return expr, err
+	if l.pos+1 < len(l.src) {
+		text := l.src[l.pos : l.pos+2]
+		if op, ok := twoCharOperators[text]; ok {
+			l.pos += 2
+			return token{kind: tokOperator, pos: start, text: text, op: op}, nil
+		}
 	}
-	test, err := compile_test(strings.Join(main.Left, ""))
-	if err != nil {
-		return expr, err
+	if op, ok := oneCharOperators[c]; ok {
+		l.pos++
+		return token{kind: tokOperator, pos: start, text: string(c), op: op}, nil
 	}
-	actions, err := split_tokens(main.Right, ":")
-	if err != nil {
-		return expr, err
+	kind := tokEOF
+	switch c {
+	case '!':
+		kind = tokNot
+	case '?':
+		kind = tokQuestion
+	case ':':
+		kind = tokColon
+	case '(':
+		kind = tokLeftParen
+	case ')':
+		kind = tokRightParen
+	default:
+		return token{}, l.errorf(start, "unexpected character %q", c)
 	}
-	true_action, err := compile_expression(strings.Join(actions.Left, ""))
-	if err != nil {
-		return expr, err
+	l.pos++
+	return token{kind: kind, pos: start, text: string(c)}, nil
+}
+
+// maxDepth limits the nesting of expressions, as libintl's parser
+// limits its stack to YYMAXDEPTH entries, so that parsing or
+// evaluating an untrusted expression cannot overflow the stack.
+const maxDepth = 10000
+
+// parser is a recursive descent parser for the C subset accepted by
+// libintl's plural.y.  Binary operators are parsed by precedence
+// climbing.
+type parser struct {
+	lex lexer
+	tok token
+	// depth counts the nested expressions being parsed, and the
+	// operators of a chain of binary operators, which nest the
+	// nodes of the syntax tree.
+	depth int
+}
+
+// enter records a level of nesting, returning an error if there are
+// more than maxDepth.  Callers restore the depth when they return.
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return p.lex.errorf(p.tok.pos, "expression nested more than %d levels deep", maxDepth)
 	}
-	false_action, err := compile_expression(strings.Join(actions.Right, ""))
+	return nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
 	if err != nil {
-		return expr, nil
+		return err
 	}
-	return ternary{
-		test:       test,
-		true_expr:  true_action,
-		false_expr: false_action,
-	}, nil
+	p.tok = tok
+	return nil
 }
 
-var const_token const_val_
-
-type const_val_ struct{}
-
-func (const_val_) compile(tokens []string) (expr Expression, err error) {
-	if len(tokens) == 0 {
-		return expr, errors.New("Got nothing instead of constant")
-	}
-	if len(tokens) != 1 {
-		return expr, fmt.Errorf("Invalid constant: %s", strings.Join(tokens, ""))
-	}
-	i, err := strconv.Atoi(tokens[0])
-	if err != nil {
-		return expr, err
+// unexpected reports an error for the current token.
+func (p *parser) unexpected(expecting string) error {
+	if p.tok.kind == tokEOF {
+		return p.lex.errorf(p.tok.pos, "unexpected end of expression, expecting %s", expecting)
 	}
-	return const_value{value: i}, nil
+	return p.lex.errorf(p.tok.pos, "unexpected %q, expecting %s", p.tok.text, expecting)
 }
 
-func compile_logic_test(tokens []string, sep string, builder logic_test_build) (test test, err error) {
-	split, err := split_tokens(tokens, sep)
-	if err != nil {
-		return test, err
+// parseExpression parses a conditional expression, which has the
+// lowest precedence and associates to the right.
+func (p *parser) parseExpression() (Node, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	if err := p.enter(); err != nil {
+		return nil, err
 	}
-	left, err := compile_test(strings.Join(split.Left, ""))
+	cond, err := p.parseBinary(OpOr.Precedence())
 	if err != nil {
-		return test, err
+		return nil, err
 	}
-	right, err := compile_test(strings.Join(split.Right, ""))
-	if err != nil {
-		return test, err
+	if p.tok.kind != tokQuestion {
+		return cond, nil
 	}
-	return builder(left, right), nil
-}
-
-var or_token or_
-
-type or_ struct{}
-
-func (or_) compile(tokens []string) (test test, err error) {
-	return compile_logic_test(tokens, "||", build_or)
-}
-func build_or(left test, right test) test {
-	return or{left: left, right: right}
-}
-
-var and_token and_
-
-type and_ struct{}
-
-func (and_) compile(tokens []string) (test test, err error) {
-	return compile_logic_test(tokens, "&&", build_and)
-}
-func build_and(left test, right test) test {
-	return and{left: left, right: right}
-}
-
-func compile_mod(tokens []string) (math math, err error) {
-	split, err := split_tokens(tokens, "%")
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	then, err := p.parseExpression()
 	if err != nil {
-		return math, err
+		return nil, err
 	}
-	if len(split.Left) != 1 || split.Left[0] != "n" {
-		return math, errors.New("Modulus operation requires 'n' as left operand")
+	if p.tok.kind != tokColon {
+		return nil, p.unexpected("':'")
 	}
-	if len(split.Right) != 1 {
-		return math, errors.New("Modulus operation requires simple integer as right operand")
+	if err := p.advance(); err != nil {
+		return nil, err
 	}
-	i, err := parse_uint32(split.Right[0])
+	other, err := p.parseExpression()
 	if err != nil {
-		return math, err
+		return nil, err
 	}
-	return mod{value: uint32(i)}, nil
+	return Ternary{Cond: cond, Then: then, Else: other}, nil
 }
 
-func _pipe(mod_tokens []string, action_tokens []string, builder cmp_test_builder, flipped bool) (test test, err error) {
-	modifier, err := compile_mod(mod_tokens)
+// parseBinary parses a sequence of unary expressions joined by binary
+// operators binding at least as tightly as minPrecedence.  All binary
+// operators associate to the left.
+func (p *parser) parseBinary(minPrecedence int) (Node, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	x, err := p.parseUnary()
 	if err != nil {
-		return test, err
-	}
-	if len(action_tokens) != 1 {
-		return test, errors.New("Can only get modulus of integer")
+		return nil, err
 	}
-	i, err := parse_uint32(action_tokens[0])
-	if err != nil {
-		return test, err
+	for p.tok.kind == tokOperator && p.tok.op.Precedence() >= minPrecedence {
+		op := p.tok.op
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		y, err := p.parseBinary(op.Precedence() + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = Binary{Op: op, X: x, Y: y}
 	}
-	action := builder(uint32(i), flipped)
-	return pipe{
-		modifier: modifier,
-		action:   action,
-	}, nil
+	return x, nil
 }
 
-func compile_equality(tokens []string, sep string, builder cmp_test_builder) (test test, err error) {
-	split, err := split_tokens(tokens, sep)
-	if err != nil {
-		return test, err
+func (p *parser) parseUnary() (Node, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	if err := p.enter(); err != nil {
+		return nil, err
 	}
-	if len(split.Left) == 1 && split.Left[0] == "n" {
-		if len(split.Right) != 1 {
-			return test, errors.New("test can only compare n to integers")
+	tok := p.tok
+	switch tok.kind {
+	case tokNot:
+		if err := p.advance(); err != nil {
+			return nil, err
 		}
-		i, err := parse_uint32(split.Right[0])
+		x, err := p.parseUnary()
 		if err != nil {
-			return test, err
+			return nil, err
 		}
-		return builder(i, false), nil
-	} else if len(split.Right) == 1 && split.Right[0] == "n" {
-		if len(split.Left) != 1 {
-			return test, errors.New("test can only compare n to integers")
+		return Not{X: x}, nil
+	case tokLeftParen:
+		if err := p.advance(); err != nil {
+			return nil, err
 		}
-		i, err := parse_uint32(split.Left[0])
+		x, err := p.parseExpression()
 		if err != nil {
-			return test, err
+			return nil, err
 		}
-		return builder(i, true), nil
-	} else if contains(split.Left, "n") && contains(split.Left, "%") {
-		return _pipe(split.Left, split.Right, builder, false)
-	} else {
-		return test, errors.New("equality test must have 'n' as one of the two tests")
-	}
-}
-
-var eq_token eq_
-
-type eq_ struct{}
-
-func (eq_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, "==", build_eq)
-}
-func build_eq(val uint32, flipped bool) test {
-	return equal{value: val}
-}
-
-var neq_token neq_
-
-type neq_ struct{}
-
-func (neq_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, "!=", build_neq)
-}
-func build_neq(val uint32, flipped bool) test {
-	return notequal{value: val}
-}
-
-var gt_token gt_
-
-type gt_ struct{}
-
-func (gt_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, ">", build_gt)
-}
-func build_gt(val uint32, flipped bool) test {
-	return gt{value: val, flipped: flipped}
-}
-
-var gte_token gte_
-
-type gte_ struct{}
-
-func (gte_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, ">=", build_gte)
-}
-func build_gte(val uint32, flipped bool) test {
-	return gte{value: val, flipped: flipped}
-}
-
-var lt_token lt_
-
-type lt_ struct{}
-
-func (lt_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, "<", build_lt)
-}
-func build_lt(val uint32, flipped bool) test {
-	return lt{value: val, flipped: flipped}
-}
-
-var lte_token lte_
-
-type lte_ struct{}
-
-func (lte_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, "<=", build_lte)
-}
-func build_lte(val uint32, flipped bool) test {
-	return lte{value: val, flipped: flipped}
-}
-
-type test_token_def struct {
-	op    string
-	token test_token
-}
-
-var precedence = []test_token_def{
-	test_token_def{op: "||", token: or_token},
-	test_token_def{op: "&&", token: and_token},
-	test_token_def{op: "==", token: eq_token},
-	test_token_def{op: "!=", token: neq_token},
-	test_token_def{op: ">=", token: gte_token},
-	test_token_def{op: ">", token: gt_token},
-	test_token_def{op: "<=", token: lte_token},
-	test_token_def{op: "<", token: lt_token},
-}
-
-type splitted struct {
-	Left  []string
-	Right []string
-}
-
-// Find index of token in list of tokens
-func index(tokens []string, sep string) int {
-	for index, token := range tokens {
-		if token == sep {
-			return index
+		if p.tok.kind != tokRightParen {
+			return nil, p.unexpected("')'")
 		}
-	}
-	return -1
-}
-
-// Split a list of tokens by a token into a splitted struct holding the tokens
-// before and after the token to be split by.
-func split_tokens(tokens []string, sep string) (s splitted, err error) {
-	index := index(tokens, sep)
-	if index == -1 {
-		return s, fmt.Errorf("'%s' not found in ['%s']", sep, strings.Join(tokens, "','"))
-	}
-	return splitted{
-		Left:  tokens[:index],
-		Right: tokens[index+1:],
-	}, nil
-}
-
-// Scan a string for parenthesis
-func scan(s string) <-chan match {
-	ch := make(chan match)
-	go func() {
-		depth := 0
-		opener := 0
-		for index, char := range s {
-			switch char {
-			case '(':
-				if depth == 0 {
-					opener = index
-				}
-				depth++
-			case ')':
-				depth--
-				if depth == 0 {
-					ch <- match{
-						open_pos: opener,
-						close_pos: index + 1,
-					}
-				}
-			}
-
+		if err := p.advance(); err != nil {
+			return nil, err
 		}
-		close(ch)
-	}()
-	return ch
-}
-
-// Split the string into tokens
-func split(s string) <- chan string {
-	ch := make(chan string)
-	go func() {
-		s = strings.Replace(s, " ", "", -1)
-		if !strings.Contains(s, "(") {
-			ch <- s
-		} else {
-			last := 0
-			end := len(s)
-			for info := range scan(s) {
-				if last != info.open_pos {
-					ch <- s[last:info.open_pos]
-				}
-				ch <- s[info.open_pos:info.close_pos]
-				last = info.close_pos
-			}
-			if last != end {
-				ch <- s[last:]
-			}
+		return x, nil
+	case tokVariable:
+		if err := p.advance(); err != nil {
+			return nil, err
 		}
-		close(ch)
-	}()
-	return ch
-}
-
-// Tokenizes a string into a list of strings, tokens grouped by parenthesis are
-// not split! If the string starts with ( and ends in ), those are stripped.
-func tokenize(s string) []string {
-	/*
-		TODO: Properly detect if the string starts with a ( and ends with a )
-		and that those two form a matching pair.
-
-		Eg: (foo) -> true; (foo)(bar) -> false;
-	*/
-	if s[0] == '(' && s[len(s)-1] == ')' {
-		s = s[1 : len(s)-1]
-	}
-	ret := []string{}
-	for chunk := range split(s) {
-		if len(chunk) != 0 {
-			if chunk[0] == '(' && chunk[len(chunk)-1] == ')' {
-				ret = append(ret, chunk)
-			} else {
-				for _, token := range pat.FindAllStringSubmatch(chunk, -1) {
-					ret = append(ret, token[0])
-				}
-			}
-		} else {
-			fmt.Printf("Empty chunk in string '%s'\n", s)
+		return Variable{}, nil
+	case tokNumber:
+		if err := p.advance(); err != nil {
+			return nil, err
 		}
+		return Constant{Value: tok.value}, nil
 	}
-	return ret
+	return nil, p.unexpected("'n', a number, '!' or '('")
 }
 
-// Compile a string containing a plural form expression to a Expression object.
-func Compile(s string) (expr Expression, err error) {
-	if s == "0" {
-		return const_value{value: 0}, nil
+// Parse parses a plural expression, returning its syntax tree.  It
+// accepts the same syntax as Compile.
+func Parse(s string) (Node, error) {
+	p := &parser{lex: lexer{src: s}}
+	if err := p.advance(); err != nil {
+		return nil, err
 	}
-	if !strings.Contains(s, "?") {
-		s += "?1:0"
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
 	}
-	return compile_expression(s)
-}
-
-// Check if a token is in a slice of strings
-func contains(haystack []string, needle string) bool {
-	for _, s := range haystack {
-		if s == needle {
-			return true
-		}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected("operator or end of expression")
 	}
-	return false
+	return root, nil
 }
 
-// Compiles an expression (ternary or constant)
-func compile_expression(s string) (expr Expression, err error) {
-	tokens := tokenize(s)
-	if contains(tokens, "?") {
-		return ternary_token.compile(tokens)
-	} else {
-		return const_token.compile(tokens)
-	}
-}
+// maxCompileLength is the length in bytes of the longest expression
+// accepted by Compile.  The plural expressions of real languages are
+// far shorter, and the limit bounds the time taken to build lookup
+// tables for the expressions of untrusted catalogs.
+const maxCompileLength = 4096
 
-// Compiles a test (comparison)
-func compile_test(s string) (test test, err error) {
-	tokens := tokenize(s)
-	for _, token_def := range precedence {
-		if contains(tokens, token_def.op) {
-			return token_def.token.compile(tokens)
+// Compile a string containing a plural form expression to a Expression object.
+//
+// The expression uses the C syntax accepted by GNU gettext: the
+// variable n, unsigned integer constants, the conditional operator,
+// the binary operators || && == != < > <= >= + - * / % and the unary
+// operator !, with C precedence.  Expressions longer than 4096 bytes
+// are rejected.  Errors are returned as *SyntaxError.
+func Compile(s string) (expr Expression, err error) {
+	if len(s) > maxCompileLength {
+		return nil, &SyntaxError{
+			Expr:   s,
+			Column: maxCompileLength + 1,
+			Msg:    fmt.Sprintf("expression longer than %d bytes", maxCompileLength),
 		}
 	}
-	return test, errors.New("Cannot compile")
-}
-
-func parse_uint32(s string) (ui uint32, err error) {
-	i, err := strconv.ParseUint(s, 10, 32)
+	root, err := Parse(s)
 	if err != nil {
-		return ui, err
-	} else {
-		return uint32(i), nil
+		return nil, err
 	}
+	return newExpression(root), nil
 }
@@ -3,6 +3,7 @@ package pluralforms
 import (
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -11,17 +12,23 @@ type fixture struct {
 	Fixture    []int
 }
 
-func TestCompiler(t *testing.T) {
+func loadFixtures(t testing.TB) []fixture {
 	f, err := os.Open("testdata/plural_forms.json")
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer f.Close()
 	dec := json.NewDecoder(f)
 	var fixtures []fixture
 	err = dec.Decode(&fixtures)
 	if err != nil {
 		t.Fatal(err)
 	}
+	return fixtures
+}
+
+func TestCompiler(t *testing.T) {
+	fixtures := loadFixtures(t)
 	for _, data := range fixtures {
 		expr, err := Compile(data.PluralForm)
 		if err != nil {
@@ -43,3 +50,261 @@ func TestCompiler(t *testing.T) {
 		}
 	}
 }
+
+func TestCompilerGrammar(t *testing.T) {
+	for _, test := range []struct {
+		expr     string
+		n        uint32
+		expected int
+	}{
+		{"0", 5, 0},
+		{"n != 1", 1, 0},
+		{"n != 1", 2, 1},
+		{"n>1", 0, 0},
+		{"!n", 0, 1},
+		{"!!n", 7, 1},
+		{"1+2*3", 0, 7},
+		{"(1+2)*3", 0, 9},
+		{"10-4-3", 0, 3},
+		{"100/10/5", 0, 2},
+		{"n%0", 7, 0},
+		{"n/0", 7, 0},
+		{"n-2", 1, -1},
+		{"1 || 2/0", 0, 1},
+		{"0 && n", 1, 0},
+		{"n<2 == n<3", 2, 0},
+		{"1 ? 2 : 3 ? 4 : 5", 0, 2},
+		{"0 ? 2 : 0 ? 4 : 5", 0, 5},
+		{"n ? n == 1 ? 0 : 1 : 2", 0, 2},
+		{"((n))", 3, 3},
+		{"\tn\t==\t1", 1, 1},
+		{"n==1 ? 0 : 1; nplurals=2", 1, 0},
+		{"n==1 ? 0 : 1\ntrailing", 2, 1},
+	} {
+		expr, err := Compile(test.expr)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.expr, err)
+			continue
+		}
+		if i := expr.Eval(test.n); i != test.expected {
+			t.Errorf("%q with n = %d: expected %d, got %d", test.expr, test.n, test.expected, i)
+		}
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+	for _, test := range []struct {
+		expr   string
+		column int
+		msg    string
+	}{
+		{"", 1, "unexpected end of expression, expecting 'n', a number, '!' or '('"},
+		{"n ==", 5, "unexpected end of expression, expecting 'n', a number, '!' or '('"},
+		{"n x 1", 3, "unexpected character 'x'"},
+		{"(n)(n)", 4, "unexpected \"(\", expecting operator or end of expression"},
+		{"(n == 1", 8, "unexpected end of expression, expecting ')'"},
+		{"n ? 1", 6, "unexpected end of expression, expecting ':'"},
+		{"n = 1", 3, "unexpected character '='"},
+		{"n + -1", 5, "unexpected \"-\", expecting 'n', a number, '!' or '('"},
+		{"n % 99999999999999999999", 5, "number 99999999999999999999 out of range"},
+		{"n == 1)", 7, "unexpected \")\", expecting operator or end of expression"},
+	} {
+		_, err := Compile(test.expr)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: expected *SyntaxError, got %v", test.expr, err)
+			continue
+		}
+		if syntaxErr.Expr != test.expr || syntaxErr.Column != test.column || syntaxErr.Msg != test.msg {
+			t.Errorf("%q: unexpected error %#v", test.expr, syntaxErr)
+		}
+	}
+}
+
+func TestCompilerDepth(t *testing.T) {
+	for _, test := range []struct {
+		expr   string
+		column int
+	}{
+		{strings.Repeat("!", 3<<20) + "n", maxDepth},
+		// Parentheses nest a conditional expression within
+		// a unary expression
+		{strings.Repeat("(", 3<<20) + "n" + strings.Repeat(")", 3<<20), maxDepth/2 + 1},
+		{strings.Repeat("n ? 1 : ", 20000) + "0", 8*maxDepth - 11},
+		{strings.Repeat("n + ", 20000) + "n", 4*maxDepth - 3},
+	} {
+		_, err := Parse(test.expr)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%.20q: expected *SyntaxError, got %T", test.expr, err)
+			continue
+		}
+		if syntaxErr.Column != test.column || syntaxErr.Msg != "expression nested more than 10000 levels deep" {
+			t.Errorf("%.20q: unexpected error at column %d: %s", test.expr, syntaxErr.Column, syntaxErr.Msg)
+		}
+	}
+
+	// Expressions nested within the limit are accepted
+	for _, expr := range []string{
+		strings.Repeat("!", maxDepth/2) + "n",
+		strings.Repeat("(", maxDepth/3) + "n" + strings.Repeat(")", maxDepth/3),
+		strings.Repeat("n + ", maxDepth/3) + "n",
+	} {
+		if _, err := Parse(expr); err != nil {
+			t.Errorf("%.20q: unexpected error: %v", expr, err)
+		}
+	}
+}
+
+func TestCompilerLength(t *testing.T) {
+	expr := strings.Repeat("n%7 + ", 1000) + "n%3 ? 1 : 0"
+	_, err := Compile(expr)
+	syntaxErr, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("expected *SyntaxError, got %T", err)
+	}
+	if syntaxErr.Column != maxCompileLength+1 || syntaxErr.Msg != "expression longer than 4096 bytes" {
+		t.Errorf("unexpected error at column %d: %s", syntaxErr.Column, syntaxErr.Msg)
+	}
+	// Long expressions can still be parsed
+	if _, err := Parse(expr); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// An expression just within the limit is accepted
+	expr = strings.Repeat("n%7 + ", 680) + "n%3 ? 1 : 0"
+	if len(expr) > maxCompileLength {
+		t.Fatalf("expression of %d bytes is too long", len(expr))
+	}
+	if _, err := Compile(expr); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	node, err := Parse("n%10==1 && n%100!=11 ? 0 : 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Ternary{
+		Cond: Binary{
+			Op: OpAnd,
+			X: Binary{
+				Op: OpEqual,
+				X:  Binary{Op: OpModulo, X: Variable{}, Y: Constant{Value: 10}},
+				Y:  Constant{Value: 1},
+			},
+			Y: Binary{
+				Op: OpNotEqual,
+				X:  Binary{Op: OpModulo, X: Variable{}, Y: Constant{Value: 100}},
+				Y:  Constant{Value: 11},
+			},
+		},
+		Then: Constant{Value: 0},
+		Else: Constant{Value: 1},
+	}
+	if node != Node(expected) {
+		t.Errorf("unexpected tree %#v", node)
+	}
+}
+
+func TestNodeString(t *testing.T) {
+	for _, test := range []struct {
+		expr     string
+		expected string
+	}{
+		{"n", "n"},
+		{"(n != 1)", "n != 1"},
+		{"n==1?0:n%10>=2&&n%10<=4&&(n%100<10||n%100>=20)?1:2",
+			"n == 1 ? 0 : n % 10 >= 2 && n % 10 <= 4 && (n % 100 < 10 || n % 100 >= 20) ? 1 : 2"},
+		{"(n%10+n/10)%3", "(n % 10 + n / 10) % 3"},
+		{"10-(4-3)", "10 - (4 - 3)"},
+		{"(10-4)-3", "10 - 4 - 3"},
+		{"!(n==1)", "!(n == 1)"},
+		{"!!n", "!!n"},
+		{"(n ? 1 : 2) ? 3 : 4", "(n ? 1 : 2) ? 3 : 4"},
+		{"n ? (n ? 1 : 2) : (n ? 3 : 4)", "n ? n ? 1 : 2 : n ? 3 : 4"},
+		{"(n ? 1 : 2) + 3", "(n ? 1 : 2) + 3"},
+		{"n  ==  1 ; nplurals=2", "n == 1"},
+	} {
+		node, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.expr, err)
+			continue
+		}
+		s := node.String()
+		if s != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.expr, test.expected, s)
+		}
+		// The canonical form must parse to the same tree
+		reparsed, err := Parse(s)
+		if err != nil {
+			t.Errorf("%q: canonical form %q does not parse: %s", test.expr, s, err)
+		} else if reparsed != node {
+			t.Errorf("%q: canonical form %q parses to a different tree", test.expr, s)
+		}
+	}
+}
+
+func TestEval64(t *testing.T) {
+	expr, err := Compile("n%10==1 && n%100!=11 ? 0 : n != 0 ? 1 : 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		n        uint64
+		expected int
+	}{
+		{0, 2},
+		{1, 0},
+		{4294967296, 1},
+		{4294967297, 1},
+		{4294967301, 0},
+		{18446744073709551615, 1},
+	} {
+		if i := expr.(Expression64).Eval64(test.n); i != test.expected {
+			t.Errorf("n = %d: expected %d, got %d", test.n, test.expected, i)
+		}
+	}
+}
+
+func FuzzCompile(f *testing.F) {
+	for _, data := range loadFixtures(f) {
+		f.Add(data.PluralForm)
+	}
+	for _, rule := range languageRules {
+		f.Add(rule)
+	}
+	f.Add("n%0 == n/0")
+	f.Add("(((((((((((((((((n+1)+2)+3)+4)+5)+6)+7)+8)+9)+10)+11)+12)+13)+14)+15)+16)+17)")
+	// Deeply nested expressions, within and beyond maxDepth
+	f.Add(strings.Repeat("!", 4000) + "n")
+	f.Add(strings.Repeat("(", 2000) + "n" + strings.Repeat(")", 2000))
+	f.Add(strings.Repeat("!", 2*maxDepth) + "n")
+	f.Add(strings.Repeat("(", maxDepth) + "n" + strings.Repeat(")", maxDepth))
+	f.Fuzz(func(t *testing.T, s string) {
+		expr, err := Compile(s)
+		if err != nil {
+			// Parse accepts expressions of any length, but
+			// must still reject deep nesting with an error
+			Parse(s)
+			return
+		}
+		root, err := Parse(s)
+		if err != nil {
+			t.Fatalf("%q compiled but did not parse: %v", s, err)
+		}
+		// The canonical form parses to the same expression
+		reparsed, err := Parse(root.String())
+		if err != nil {
+			t.Fatalf("%q: canonical form %q does not parse: %v", s, root.String(), err)
+		}
+		if reparsed.String() != root.String() {
+			t.Fatalf("%q: canonical form %q reparsed as %q", s, root.String(), reparsed.String())
+		}
+		for _, n := range []uint64{0, 1, 2, 5, 11, 100, 1000, 4095, 4096, 1 << 31, 1<<32 - 1, 1 << 32, 1<<64 - 1} {
+			if got, expected := expr.(Expression64).Eval64(n), int(root.Eval(n)); got != expected {
+				t.Fatalf("%q with n = %d: expected %d, got %d", s, n, expected, got)
+			}
+		}
+	})
+}
@@ -1,39 +1,211 @@
 package pluralforms
 
+import (
+	"strconv"
+)
+
 // Expression is a plurfalforms expression. Eval evaluates the expression for
 // a given n value. Use pluralforms.Compile to generate Expression instances.
 type Expression interface {
 	Eval(n uint32) int
 }
 
-type const_value struct {
-	value int
+// Expression64 is an Expression that can also be evaluated for a
+// 64-bit n.  The expressions returned by Compile implement it.
+type Expression64 interface {
+	Expression
+	// Eval64 evaluates the expression for a 64-bit n.  As with
+	// libintl on 64-bit systems, the expression is evaluated with
+	// 64-bit unsigned arithmetic.
+	Eval64(n uint64) int
 }
 
-func (c const_value) Eval(n uint32) int {
-	return c.value
+// Node is a node of the syntax tree of a plural expression, as
+// returned by Parse.  It is one of Variable, Constant, Not, Binary or
+// Ternary.
+//
+// Like libintl, nodes are evaluated using unsigned long arithmetic.
+// String returns the expression in a canonical form, which parses to
+// an identical tree, so two expressions can be compared by their
+// String values.
+type Node interface {
+	Eval(n uint64) uint64
+	String() string
+	isNode()
 }
 
-type test interface {
-	test(n uint32) bool
+// expression is a compiled plural expression.  Results are looked up
+// in a table where possible, falling back to running the expression's
+// program.
+type expression struct {
+	root Node
+	prog program
+	// table holds the values of the expression for n below its
+	// length.  If the expression is periodic, it covers all n
+	// below threshold+period.
+	table     []uint8
+	threshold uint64
+	period    uint64
 }
 
-type ternary struct {
-	test       test
-	true_expr  Expression
-	false_expr Expression
-}
+var _ Expression64 = (*expression)(nil)
 
-func (t ternary) Eval(n uint32) int {
-	if t.test.test(n) {
-		if t.true_expr == nil {
-			return -1
+func newExpression(root Node) *expression {
+	e := &expression{
+		root: root,
+		prog: compileProgram(root),
+	}
+	size := uint64(tableSize)
+	e.threshold, e.period = findPeriod(root)
+	if e.period != 0 {
+		size = e.threshold + e.period
+	}
+	table := make([]uint8, size)
+	for n := range table {
+		value := e.prog.eval(uint64(n))
+		if value > 255 {
+			// Unusual expression, so don't bother with a
+			// table
+			e.period = 0
+			return e
 		}
-		return t.true_expr.Eval(n)
-	} else {
-		if t.false_expr == nil {
-			return -1
+		table[n] = uint8(value)
+	}
+	e.table = table
+	return e
+}
+
+func (e *expression) Eval(n uint32) int {
+	return int(e.eval(uint64(n)))
+}
+
+func (e *expression) Eval64(n uint64) int {
+	return int(e.eval(n))
+}
+
+func (e *expression) eval(n uint64) uint64 {
+	if n < uint64(len(e.table)) {
+		return uint64(e.table[n])
+	}
+	if e.period != 0 {
+		return uint64(e.table[e.threshold+(n-e.threshold)%e.period])
+	}
+	return e.prog.eval(n)
+}
+
+func (e *expression) String() string {
+	return e.root.String()
+}
+
+// Variable is the plural count, n.
+type Variable struct{}
+
+func (Variable) Eval(n uint64) uint64 {
+	return n
+}
+
+func (Variable) String() string {
+	return "n"
+}
+
+// Constant is an unsigned integer constant.
+type Constant struct {
+	Value uint64
+}
+
+func (c Constant) Eval(n uint64) uint64 {
+	return c.Value
+}
+
+func (c Constant) String() string {
+	return strconv.FormatUint(c.Value, 10)
+}
+
+// Not is the logical negation !X.
+type Not struct {
+	X Node
+}
+
+func (e Not) Eval(n uint64) uint64 {
+	return boolValue(e.X.Eval(n) == 0)
+}
+
+func (e Not) String() string {
+	switch e.X.(type) {
+	case Binary, Ternary:
+		return "!(" + e.X.String() + ")"
+	}
+	return "!" + e.X.String()
+}
+
+// Binary is a logical, comparison or arithmetic operation X Op Y.
+type Binary struct {
+	Op   Operator
+	X, Y Node
+}
+
+func (e Binary) Eval(n uint64) uint64 {
+	// The logical operators only evaluate the right operand
+	// if needed.
+	switch e.Op {
+	case OpOr:
+		return boolValue(e.X.Eval(n) != 0 || e.Y.Eval(n) != 0)
+	case OpAnd:
+		return boolValue(e.X.Eval(n) != 0 && e.Y.Eval(n) != 0)
+	}
+	return e.Op.apply(e.X.Eval(n), e.Y.Eval(n))
+}
+
+func (e Binary) String() string {
+	// Binary operators associate to the left, so the right
+	// operand needs parentheses at equal precedence.
+	prec := e.Op.Precedence()
+	return parenthesize(e.X, prec) + " " + e.Op.String() + " " + parenthesize(e.Y, prec+1)
+}
+
+// Ternary is the conditional expression Cond ? Then : Else.
+type Ternary struct {
+	Cond       Node
+	Then, Else Node
+}
+
+func (e Ternary) Eval(n uint64) uint64 {
+	if e.Cond.Eval(n) != 0 {
+		return e.Then.Eval(n)
+	}
+	return e.Else.Eval(n)
+}
+
+func (e Ternary) String() string {
+	// The conditional operator associates to the right, so only
+	// a nested condition needs parentheses.
+	return parenthesize(e.Cond, OpOr.Precedence()) + " ? " + e.Then.String() + " : " + e.Else.String()
+}
+
+func (Variable) isNode() {}
+func (Constant) isNode() {}
+func (Not) isNode()      {}
+func (Binary) isNode()   {}
+func (Ternary) isNode()  {}
+
+// parenthesize formats an operand, adding parentheses if it binds
+// less tightly than minPrecedence.
+func parenthesize(x Node, minPrecedence int) string {
+	switch x := x.(type) {
+	case Binary:
+		if x.Op.Precedence() >= minPrecedence {
+			return x.String()
 		}
-		return t.false_expr.Eval(n)
+	case Ternary:
+	default:
+		return x.String()
+	}
+	return "(" + x.String() + ")"
+}
+
+func boolValue(b bool) uint64 {
+	if b {
+		return 1
 	}
+	return 0
 }
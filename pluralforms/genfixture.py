@@ -15,6 +15,12 @@ PLURAL_FORMS = [
     "n==1?0:n%10>=2&&n%10<=4&&(n%100<10||n%100>=20)?1:2",
     "n%100==1?0:n%100==2?1:n%100==3||n%100==4?2:3",
     "n==0?0:n==1?1:n==2?2:n%100>=3&&n%100<=10?3:n%100>=11?4:5",
+    "!(n==1)",
+    "(n%10+n/10)%3",
+    "n*2>5?n-2:0",
+    "2+3*n%7",
+    "(n%10==1)+(n%100==11)",
+    "n/10%10==1?2:n%10==1?0:1",
 ]
 
 NUM = 1000
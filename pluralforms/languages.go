@@ -0,0 +1,80 @@
+package pluralforms
+
+import (
+	"strings"
+)
+
+// languageRules holds the standard plural forms of languages, as
+// listed in GNU gettext's plural-table.c.
+var languageRules = map[string]string{
+	"ja":    "nplurals=1; plural=0;",
+	"vi":    "nplurals=1; plural=0;",
+	"ko":    "nplurals=1; plural=0;",
+	"zh":    "nplurals=1; plural=0;",
+	"en":    "nplurals=2; plural=(n != 1);",
+	"de":    "nplurals=2; plural=(n != 1);",
+	"nl":    "nplurals=2; plural=(n != 1);",
+	"sv":    "nplurals=2; plural=(n != 1);",
+	"da":    "nplurals=2; plural=(n != 1);",
+	"no":    "nplurals=2; plural=(n != 1);",
+	"nb":    "nplurals=2; plural=(n != 1);",
+	"nn":    "nplurals=2; plural=(n != 1);",
+	"fo":    "nplurals=2; plural=(n != 1);",
+	"es":    "nplurals=2; plural=(n != 1);",
+	"pt":    "nplurals=2; plural=(n != 1);",
+	"it":    "nplurals=2; plural=(n != 1);",
+	"bg":    "nplurals=2; plural=(n != 1);",
+	"el":    "nplurals=2; plural=(n != 1);",
+	"fi":    "nplurals=2; plural=(n != 1);",
+	"et":    "nplurals=2; plural=(n != 1);",
+	"he":    "nplurals=2; plural=(n != 1);",
+	"eo":    "nplurals=2; plural=(n != 1);",
+	"hu":    "nplurals=2; plural=(n != 1);",
+	"tr":    "nplurals=2; plural=(n != 1);",
+	"pt_BR": "nplurals=2; plural=(n > 1);",
+	"fr":    "nplurals=2; plural=(n > 1);",
+	"lv":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n != 0 ? 1 : 2);",
+	"ga":    "nplurals=3; plural=n==1 ? 0 : n==2 ? 1 : 2;",
+	"ro":    "nplurals=3; plural=n==1 ? 0 : (n==0 || (n%100 > 0 && n%100 < 20)) ? 1 : 2;",
+	"lt":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"ru":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"uk":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"be":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"sr":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"hr":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"cs":    "nplurals=3; plural=(n==1) ? 0 : (n>=2 && n<=4) ? 1 : 2;",
+	"sk":    "nplurals=3; plural=(n==1) ? 0 : (n>=2 && n<=4) ? 1 : 2;",
+	"pl":    "nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"sl":    "nplurals=4; plural=(n%100==1 ? 0 : n%100==2 ? 1 : n%100==3 || n%100==4 ? 2 : 3);",
+	"ar":    "nplurals=6; plural=(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5);",
+}
+
+// ForLanguage returns the standard Plural-Forms header value for a
+// language, as used by msginit.  The language may be a locale name
+// such as "pt_BR.UTF-8" or "sr@latin": the codeset and modifier are
+// ignored, and the rule for the language without its territory is
+// used if there is none for the territory.
+func ForLanguage(language string) (pluralForms string, ok bool) {
+	for _, key := range languageKeys(language) {
+		if rule, ok := languageRules[key]; ok {
+			return rule, true
+		}
+	}
+	return "", false
+}
+
+// languageKeys returns the keys to look up a locale name in a table
+// of languages, most specific first.  The keys are normalised to a
+// lower case language and upper case territory, as in "pt_PT".
+func languageKeys(language string) []string {
+	if i := strings.IndexAny(language, ".@"); i >= 0 {
+		language = language[:i]
+	}
+	language = strings.Replace(language, "-", "_", -1)
+	i := strings.IndexByte(language, '_')
+	if i < 0 {
+		return []string{strings.ToLower(language)}
+	}
+	base := strings.ToLower(language[:i])
+	return []string{base + "_" + strings.ToUpper(language[i+1:]), base}
+}
@@ -0,0 +1,52 @@
+package pluralforms
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestForLanguage(t *testing.T) {
+	for _, test := range []struct {
+		language string
+		expected string
+	}{
+		{"de", "nplurals=2; plural=(n != 1);"},
+		{"de_AT.UTF-8", "nplurals=2; plural=(n != 1);"},
+		{"pt", "nplurals=2; plural=(n != 1);"},
+		{"pt_BR", "nplurals=2; plural=(n > 1);"},
+		{"pt-BR", "nplurals=2; plural=(n > 1);"},
+		{"pt_PT", "nplurals=2; plural=(n != 1);"},
+		{"pt_br", "nplurals=2; plural=(n > 1);"},
+		{"PT-BR", "nplurals=2; plural=(n > 1);"},
+		{"sr@latin", "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);"},
+		{"ja_JP.eucJP", "nplurals=1; plural=0;"},
+		{"PL", "nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);"},
+		{"tlh", ""},
+		{"", ""},
+	} {
+		rule, ok := ForLanguage(test.language)
+		if rule != test.expected || ok != (test.expected != "") {
+			t.Errorf("%q: expected %q, got %q (%v)", test.language, test.expected, rule, ok)
+		}
+	}
+}
+
+func TestLanguageRules(t *testing.T) {
+	for language, rule := range languageRules {
+		params := strings.Split(rule, ";")
+		nplurals, err := strconv.Atoi(strings.TrimPrefix(params[0], "nplurals="))
+		if err != nil {
+			t.Errorf("%s: %s", language, err)
+			continue
+		}
+		expr, err := Compile(strings.TrimPrefix(strings.TrimSpace(params[1]), "plural="))
+		if err != nil {
+			t.Errorf("%s: %s", language, err)
+			continue
+		}
+		if err := CheckRange(expr, nplurals); err != nil {
+			t.Errorf("%s: %s", language, err)
+		}
+	}
+}
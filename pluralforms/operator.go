@@ -0,0 +1,99 @@
+package pluralforms
+
+// Operator is a binary operator of the plural expression grammar.
+type Operator int
+
+const (
+	OpOr Operator = iota
+	OpAnd
+	OpEqual
+	OpNotEqual
+	OpLess
+	OpGreater
+	OpLessEqual
+	OpGreaterEqual
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpModulo
+)
+
+var operatorNames = [...]string{
+	OpOr:           "||",
+	OpAnd:          "&&",
+	OpEqual:        "==",
+	OpNotEqual:     "!=",
+	OpLess:         "<",
+	OpGreater:      ">",
+	OpLessEqual:    "<=",
+	OpGreaterEqual: ">=",
+	OpAdd:          "+",
+	OpSubtract:     "-",
+	OpMultiply:     "*",
+	OpDivide:       "/",
+	OpModulo:       "%",
+}
+
+func (op Operator) String() string {
+	return operatorNames[op]
+}
+
+// Precedence returns the binding strength of the operator, following
+// libintl's plural.y.  Higher values bind more tightly.
+func (op Operator) Precedence() int {
+	switch op {
+	case OpOr:
+		return 1
+	case OpAnd:
+		return 2
+	case OpEqual, OpNotEqual:
+		return 3
+	case OpLess, OpGreater, OpLessEqual, OpGreaterEqual:
+		return 4
+	case OpAdd, OpSubtract:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// apply evaluates the operator.  Division by zero, which would raise
+// SIGFPE in libintl, evaluates to zero.
+func (op Operator) apply(x, y uint64) uint64 {
+	switch op {
+	case OpOr:
+		return boolValue(x != 0 || y != 0)
+	case OpAnd:
+		return boolValue(x != 0 && y != 0)
+	case OpEqual:
+		return boolValue(x == y)
+	case OpNotEqual:
+		return boolValue(x != y)
+	case OpLess:
+		return boolValue(x < y)
+	case OpGreater:
+		return boolValue(x > y)
+	case OpLessEqual:
+		return boolValue(x <= y)
+	case OpGreaterEqual:
+		return boolValue(x >= y)
+	case OpAdd:
+		return x + y
+	case OpSubtract:
+		return x - y
+	case OpMultiply:
+		return x * y
+	case OpDivide:
+		if y == 0 {
+			return 0
+		}
+		return x / y
+	case OpModulo:
+		if y == 0 {
+			return 0
+		}
+		return x % y
+	}
+	panic("unknown operator")
+}
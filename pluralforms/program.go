@@ -0,0 +1,351 @@
+package pluralforms
+
+// opcode is an instruction of a compiled plural expression.
+type opcode uint8
+
+const (
+	// opPushN pushes n.
+	opPushN opcode = iota
+	// opPushConst pushes arg.
+	opPushConst
+	// opNot replaces the top of the stack with its logical
+	// negation.
+	opNot
+	// opBool replaces the top of the stack with 1 if it is
+	// non-zero, or 0 otherwise.
+	opBool
+	// opJumpIfZero pops a value, jumping to arg if it is zero.
+	opJumpIfZero
+	// opJump jumps to arg.
+	opJump
+
+	// The arithmetic and comparison operators pop y and x, and
+	// push x op y.  If the instruction is immediate, arg is used
+	// as y instead, which saves a push for the common case of a
+	// constant operand.  If the instruction has pushN set, n is
+	// pushed first, to be used as x.
+	opEqual
+	opNotEqual
+	opLess
+	opGreater
+	opLessEqual
+	opGreaterEqual
+	opAdd
+	opSubtract
+	opMultiply
+	opDivide
+	opModulo
+)
+
+// binaryOpcodes maps the non-logical operators to their opcodes.
+var binaryOpcodes = [...]opcode{
+	OpEqual:        opEqual,
+	OpNotEqual:     opNotEqual,
+	OpLess:         opLess,
+	OpGreater:      opGreater,
+	OpLessEqual:    opLessEqual,
+	OpGreaterEqual: opGreaterEqual,
+	OpAdd:          opAdd,
+	OpSubtract:     opSubtract,
+	OpMultiply:     opMultiply,
+	OpDivide:       opDivide,
+	OpModulo:       opModulo,
+}
+
+type instruction struct {
+	op        opcode
+	pushN     bool
+	immediate bool
+	arg       uint64
+}
+
+// program is a plural expression compiled to a flat sequence of
+// instructions for a stack machine, which avoids the interface
+// dispatch of walking the syntax tree.
+type program struct {
+	code []instruction
+	// depth is the largest stack depth reached by the program.
+	depth int
+}
+
+const (
+	// tableSize is the number of values precomputed for
+	// expressions without a period.
+	tableSize = 256
+	// maxTableSize bounds the table of a periodic expression.
+	maxTableSize = 4096
+)
+
+// maxStackDepth is the stack depth a program can use without
+// allocating.
+const maxStackDepth = 16
+
+func compileProgram(root Node) program {
+	var c programCompiler
+	c.compile(root)
+	return program{code: c.code, depth: c.maxDepth}
+}
+
+type programCompiler struct {
+	code     []instruction
+	depth    int
+	maxDepth int
+}
+
+// push records a value pushed on the stack.
+func (c *programCompiler) push() {
+	c.depth++
+	if c.depth > c.maxDepth {
+		c.maxDepth = c.depth
+	}
+}
+
+func (c *programCompiler) emit(op opcode, arg uint64) int {
+	switch op {
+	case opPushN, opPushConst:
+		c.push()
+	case opJumpIfZero:
+		c.depth--
+	}
+	c.code = append(c.code, instruction{op: op, arg: arg})
+	return len(c.code) - 1
+}
+
+// patch sets the target of the jump at pc to the next instruction.
+func (c *programCompiler) patch(pc int) {
+	c.code[pc].arg = uint64(len(c.code))
+}
+
+func (c *programCompiler) compile(node Node) {
+	switch node := node.(type) {
+	case Variable:
+		c.emit(opPushN, 0)
+	case Constant:
+		c.emit(opPushConst, node.Value)
+	case Not:
+		c.compile(node.X)
+		c.emit(opNot, 0)
+	case Binary:
+		switch node.Op {
+		case OpAnd:
+			// x && y:  if x is zero the result is 0,
+			// otherwise it is bool(y)
+			c.compile(node.X)
+			onFalse := c.emit(opJumpIfZero, 0)
+			c.compile(node.Y)
+			c.emit(opBool, 0)
+			end := c.emit(opJump, 0)
+			c.depth--
+			c.patch(onFalse)
+			c.emit(opPushConst, 0)
+			c.patch(end)
+		case OpOr:
+			// x || y:  if x is non-zero the result is 1,
+			// otherwise it is bool(y)
+			c.compile(node.X)
+			onFalse := c.emit(opJumpIfZero, 0)
+			c.emit(opPushConst, 1)
+			end := c.emit(opJump, 0)
+			c.depth--
+			c.patch(onFalse)
+			c.compile(node.Y)
+			c.emit(opBool, 0)
+			c.patch(end)
+		default:
+			inst := instruction{op: binaryOpcodes[node.Op]}
+			y, yConst := node.Y.(Constant)
+			// n can only be pushed by the instruction itself
+			// if y is not already on the stack
+			if _, ok := node.X.(Variable); ok && yConst {
+				inst.pushN = true
+				c.push()
+			} else {
+				c.compile(node.X)
+			}
+			if yConst {
+				inst.immediate = true
+				inst.arg = y.Value
+			} else {
+				c.compile(node.Y)
+				c.depth--
+			}
+			c.code = append(c.code, inst)
+		}
+	case Ternary:
+		c.compile(node.Cond)
+		onFalse := c.emit(opJumpIfZero, 0)
+		c.compile(node.Then)
+		end := c.emit(opJump, 0)
+		c.depth--
+		c.patch(onFalse)
+		c.compile(node.Else)
+		c.patch(end)
+	default:
+		panic("unknown node type")
+	}
+}
+
+func (p *program) eval(n uint64) uint64 {
+	var array [maxStackDepth]uint64
+	stack := array[:]
+	if p.depth > maxStackDepth {
+		stack = make([]uint64, p.depth)
+	}
+	sp := 0
+	for pc := 0; pc < len(p.code); pc++ {
+		inst := &p.code[pc]
+		switch inst.op {
+		case opPushN:
+			stack[sp] = n
+			sp++
+			continue
+		case opPushConst:
+			stack[sp] = inst.arg
+			sp++
+			continue
+		case opNot:
+			stack[sp-1] = boolValue(stack[sp-1] == 0)
+			continue
+		case opBool:
+			stack[sp-1] = boolValue(stack[sp-1] != 0)
+			continue
+		case opJumpIfZero:
+			sp--
+			if stack[sp] == 0 {
+				pc = int(inst.arg) - 1
+			}
+			continue
+		case opJump:
+			pc = int(inst.arg) - 1
+			continue
+		}
+
+		if inst.pushN {
+			stack[sp] = n
+			sp++
+		}
+		y := inst.arg
+		if !inst.immediate {
+			sp--
+			y = stack[sp]
+		}
+		x := &stack[sp-1]
+		switch inst.op {
+		case opEqual:
+			*x = boolValue(*x == y)
+		case opNotEqual:
+			*x = boolValue(*x != y)
+		case opLess:
+			*x = boolValue(*x < y)
+		case opGreater:
+			*x = boolValue(*x > y)
+		case opLessEqual:
+			*x = boolValue(*x <= y)
+		case opGreaterEqual:
+			*x = boolValue(*x >= y)
+		case opAdd:
+			*x += y
+		case opSubtract:
+			*x -= y
+		case opMultiply:
+			*x *= y
+		case opDivide:
+			if y == 0 {
+				*x = 0
+			} else {
+				*x /= y
+			}
+		case opModulo:
+			if y == 0 {
+				*x = 0
+			} else {
+				*x %= y
+			}
+		}
+	}
+	return stack[0]
+}
+
+// findPeriod determines whether the value of the expression repeats for
+// large n.  This is the case if n is only compared with constants
+// or reduced modulo constants, as in most real plural rules.  For
+// n >= threshold, the expression then has the same value for n and
+// n + period.  The period is zero if no such pattern was found.
+func findPeriod(root Node) (threshold, period uint64) {
+	period = 1
+	ok := true
+	var walk func(node Node)
+	walk = func(node Node) {
+		switch node := node.(type) {
+		case Variable:
+			// n used other than in a comparison or modulus
+			ok = false
+		case Not:
+			walk(node.X)
+		case Binary:
+			if node.Op == OpModulo {
+				if _, isVar := node.X.(Variable); isVar {
+					if k, isConst := node.Y.(Constant); isConst && k.Value != 0 {
+						if k.Value > maxTableSize {
+							ok = false
+							return
+						}
+						period = lcm(period, k.Value)
+						if period > maxTableSize {
+							ok = false
+						}
+						return
+					}
+				}
+			}
+			if node.Op.isComparison() {
+				_, xVar := node.X.(Variable)
+				_, yVar := node.Y.(Variable)
+				x, xConst := node.X.(Constant)
+				y, yConst := node.Y.(Constant)
+				if yVar && xConst {
+					y, yConst = x, xConst
+					xVar = true
+				}
+				if xVar && yConst {
+					if y.Value >= maxTableSize {
+						ok = false
+						return
+					}
+					threshold = max(threshold, y.Value+1)
+					return
+				}
+			}
+			walk(node.X)
+			walk(node.Y)
+		case Ternary:
+			walk(node.Cond)
+			walk(node.Then)
+			walk(node.Else)
+		}
+	}
+	walk(root)
+	if !ok || threshold > maxTableSize {
+		return 0, 0
+	}
+	return threshold, period
+}
+
+func (op Operator) isComparison() bool {
+	switch op {
+	case OpEqual, OpNotEqual, OpLess, OpGreater, OpLessEqual, OpGreaterEqual:
+		return true
+	}
+	return false
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func lcm(a, b uint64) uint64 {
+	return a / gcd(a, b) * b
+}
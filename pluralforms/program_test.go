@@ -0,0 +1,263 @@
+package pluralforms
+
+import (
+	"math/rand"
+	"testing"
+)
+
+const russian = "n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2"
+
+func TestProgramMatchesTree(t *testing.T) {
+	exprs := []string{
+		"!n",
+		"n",
+		"n || n-1",
+		"n && n-1 ? 7 : 3",
+		"1 < n ? 300 : n",
+		"n%0 == n/0",
+		"(((((((((((((((((n+1)+2)+3)+4)+5)+6)+7)+8)+9)+10)+11)+12)+13)+14)+15)+16)+17)",
+		"n+(1+(2+(3+(4+(5+(6+(7+(8+(9+(10+(11+(12+(13+(14+(15+(16+17))))))))))))))))",
+		"n == 5000 ? 1 : 0",
+		"n%(1+0)",
+		"n-(n/2)",
+		"n < (n%3 ? 2 : 7)",
+	}
+	for _, data := range loadFixtures(t) {
+		exprs = append(exprs, data.PluralForm)
+	}
+	rng := rand.New(rand.NewSource(1))
+	values := []uint32{4294967295, 4294967294, 1000000, 1000001, 1000011}
+	for n := uint32(0); n < 10000; n++ {
+		values = append(values, n)
+	}
+	for i := 0; i < 10000; i++ {
+		values = append(values, rng.Uint32())
+	}
+
+	for _, s := range exprs {
+		root, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog := compileProgram(root)
+		expr := newExpression(root)
+		for _, n := range values {
+			expected := root.Eval(uint64(n))
+			if got := prog.eval(uint64(n)); got != expected {
+				t.Errorf("%q: program with n = %d: expected %d, got %d", s, n, expected, got)
+				break
+			}
+			if got := expr.Eval(n); got != int(expected) {
+				t.Errorf("%q: expression with n = %d: expected %d, got %d", s, n, expected, got)
+				break
+			}
+		}
+	}
+}
+
+func TestFindPeriod(t *testing.T) {
+	for _, test := range []struct {
+		expr      string
+		threshold uint64
+		period    uint64
+	}{
+		{"0", 0, 1},
+		{"n != 1", 2, 1},
+		{"1 < n", 2, 1},
+		{"n>1 ? n==7 : 2", 8, 1},
+		{russian, 0, 100},
+		{"n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2", 2, 100},
+		{"n%3 + n%4", 0, 12},
+		{"n", 0, 0},
+		{"(n%10+n/10)%3", 0, 0},
+		{"n == 5000", 0, 0},
+		{"n % 5000", 0, 0},
+		{"n == 18446744073709551615", 0, 0},
+		{"n%4001 + n%4003", 0, 0},
+	} {
+		root, err := Parse(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		threshold, period := findPeriod(root)
+		if threshold != test.threshold || period != test.period {
+			t.Errorf("%q: expected threshold %d and period %d, got %d and %d", test.expr, test.threshold, test.period, threshold, period)
+		}
+	}
+}
+
+// benchmarkCounts is a spread of counts, mostly beyond the range of
+// the precomputed table.
+var benchmarkCounts = func() []uint32 {
+	rng := rand.New(rand.NewSource(1))
+	counts := make([]uint32, 1024)
+	for i := range counts {
+		counts[i] = rng.Uint32() % 100000
+	}
+	return counts
+}()
+
+// The baseline types are a copy of the evaluator replaced by the
+// bytecode programs, which compiled expressions to a tree of tests
+// evaluated with 32-bit arithmetic.  They are kept for comparison by
+// BenchmarkBaseline.
+type baselineExpression interface {
+	eval(n uint32) int
+}
+
+type baselineConst struct {
+	value int
+}
+
+func (c baselineConst) eval(n uint32) int {
+	return c.value
+}
+
+type baselineTernary struct {
+	test      baselineTest
+	trueExpr  baselineExpression
+	falseExpr baselineExpression
+}
+
+func (t baselineTernary) eval(n uint32) int {
+	if t.test.test(n) {
+		return t.trueExpr.eval(n)
+	}
+	return t.falseExpr.eval(n)
+}
+
+type baselineTest interface {
+	test(n uint32) bool
+}
+
+type baselineAnd struct {
+	left, right baselineTest
+}
+
+func (e baselineAnd) test(n uint32) bool {
+	return e.left.test(n) && e.right.test(n)
+}
+
+type baselineOr struct {
+	left, right baselineTest
+}
+
+func (e baselineOr) test(n uint32) bool {
+	return e.left.test(n) || e.right.test(n)
+}
+
+// baselineCmp compares n modulo mod with value, using one of the
+// comparison operators of the expression syntax.
+type baselineCmp struct {
+	mod   uint32
+	op    string
+	value uint32
+}
+
+func (e baselineCmp) test(n uint32) bool {
+	n %= e.mod
+	switch e.op {
+	case "==":
+		return n == e.value
+	case "!=":
+		return n != e.value
+	case "<":
+		return n < e.value
+	case "<=":
+		return n <= e.value
+	case ">":
+		return n > e.value
+	default:
+		return n >= e.value
+	}
+}
+
+// baselineRussian is the baseline evaluator's tree for russian.
+var baselineRussian = baselineTernary{
+	test: baselineAnd{
+		baselineCmp{10, "==", 1},
+		baselineCmp{100, "!=", 11},
+	},
+	trueExpr: baselineConst{0},
+	falseExpr: baselineTernary{
+		test: baselineAnd{
+			baselineCmp{10, ">=", 2},
+			baselineAnd{
+				baselineCmp{10, "<=", 4},
+				baselineOr{
+					baselineCmp{100, "<", 10},
+					baselineCmp{100, ">=", 20},
+				},
+			},
+		},
+		trueExpr:  baselineConst{1},
+		falseExpr: baselineConst{2},
+	},
+}
+
+func BenchmarkBaseline(b *testing.B) {
+	expr, err := Compile(russian)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, n := range benchmarkCounts {
+		if got, expected := baselineRussian.eval(n), expr.Eval(n); got != expected {
+			b.Fatalf("baseline with n = %d: expected %d, got %d", n, expected, got)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		baselineRussian.eval(benchmarkCounts[i%len(benchmarkCounts)])
+	}
+}
+
+func BenchmarkTreeWalk(b *testing.B) {
+	root, err := Parse(russian)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		root.Eval(uint64(benchmarkCounts[i%len(benchmarkCounts)]))
+	}
+}
+
+func BenchmarkProgram(b *testing.B) {
+	root, err := Parse(russian)
+	if err != nil {
+		b.Fatal(err)
+	}
+	prog := compileProgram(root)
+	for i := 0; i < b.N; i++ {
+		prog.eval(uint64(benchmarkCounts[i%len(benchmarkCounts)]))
+	}
+}
+
+func BenchmarkExpression(b *testing.B) {
+	expr, err := Compile(russian)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		expr.Eval(benchmarkCounts[i%len(benchmarkCounts)])
+	}
+}
+
+func BenchmarkExpressionSmall(b *testing.B) {
+	expr, err := Compile(russian)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		expr.Eval(uint32(i % 100))
+	}
+}
+
+func BenchmarkExpressionNonPeriodic(b *testing.B) {
+	expr, err := Compile("(n%10+n/10)%3")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		expr.Eval(benchmarkCounts[i%len(benchmarkCounts)])
+	}
+}
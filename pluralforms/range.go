@@ -0,0 +1,25 @@
+package pluralforms
+
+import (
+	"fmt"
+)
+
+// checkRangeLimit is the largest count evaluated by CheckRange, the
+// same bound used by msgfmt.
+const checkRangeLimit = 1000
+
+// CheckRange checks that expr selects one of nplurals plural forms
+// for every count from 0 to 1000, as msgfmt does when compiling a
+// catalog.
+func CheckRange(expr Expression, nplurals int) error {
+	if nplurals < 1 {
+		return fmt.Errorf("invalid nplurals value %d", nplurals)
+	}
+	for n := uint32(0); n <= checkRangeLimit; n++ {
+		index := expr.Eval(n)
+		if index < 0 || index >= nplurals {
+			return fmt.Errorf("plural expression selects form %d for n = %d, but nplurals = %d", index, n, nplurals)
+		}
+	}
+	return nil
+}
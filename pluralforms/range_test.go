@@ -0,0 +1,35 @@
+package pluralforms
+
+import (
+	"testing"
+)
+
+func TestCheckRange(t *testing.T) {
+	for _, test := range []struct {
+		expr     string
+		nplurals int
+		err      string
+	}{
+		{"0", 1, ""},
+		{"n != 1", 2, ""},
+		{"n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2", 3, ""},
+		{"n != 1", 1, "plural expression selects form 1 for n = 0, but nplurals = 1"},
+		{"n==1 ? 0 : n==2 ? 1 : 2", 2, "plural expression selects form 2 for n = 0, but nplurals = 2"},
+		{"n > 999 ? 2 : 1", 2, "plural expression selects form 2 for n = 1000, but nplurals = 2"},
+		{"n - 1", 1000, "plural expression selects form -1 for n = 0, but nplurals = 1000"},
+		{"0", 0, "invalid nplurals value 0"},
+	} {
+		expr, err := Compile(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = CheckRange(expr, test.nplurals)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%q, nplurals=%d: unexpected error: %s", test.expr, test.nplurals, err)
+			}
+		} else if err == nil || err.Error() != test.err {
+			t.Errorf("%q, nplurals=%d: expected error %q, got %v", test.expr, test.nplurals, test.err, err)
+		}
+	}
+}
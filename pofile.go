@@ -0,0 +1,576 @@
+package gettext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type pocatalog struct {
+	messages map[string][]string
+
+	catalogInfo
+}
+
+func (catalog *pocatalog) findMsg(msgid string, form form) (msgstr string, ok bool) {
+	msgstrs, ok := catalog.messages[msgid]
+	if !ok {
+		return "", false
+	}
+	// Match the mo catalog behaviour of returning the last
+	// available form if the index is out of range.
+	index := catalog.formIndex(form)
+	if index < 0 || index >= len(msgstrs) {
+		index = len(msgstrs) - 1
+	}
+	return msgstrs[index], true
+}
+
+// ParsePO parses a po file into a Catalog if possible.
+//
+// As with msgfmt, fuzzy, obsolete and untranslated entries are not
+// included in the catalog.
+func ParsePO(r io.Reader) (Catalog, error) {
+	po, err := parsePO(r)
+	if err != nil {
+		return Catalog{}, err
+	}
+	return newCatalog([]msgCatalog{po}), nil
+}
+
+func parsePO(r io.Reader) (*pocatalog, error) {
+	messages, err := ReadPO(r)
+	if err != nil {
+		return nil, err
+	}
+	catalog := &pocatalog{
+		messages: make(map[string][]string),
+	}
+	for i := range messages {
+		msg := &messages[i]
+		if msg.Obsolete {
+			continue
+		}
+		// The header entry is used even if it is marked fuzzy
+		if msg.IsHeader() {
+			if len(msg.Str) != 0 {
+				if err := catalog.read_info(msg.Str[0]); err != nil {
+					return nil, err
+				}
+				catalog.messages[""] = msg.Str
+			}
+			continue
+		}
+		if msg.HasFlag("fuzzy") || !msg.IsTranslated() {
+			continue
+		}
+		catalog.messages[msg.key()] = msg.Str
+	}
+	// The header may follow other entries, so plural entries are
+	// checked once it has been read.
+	for i := range messages {
+		msg := &messages[i]
+		if msg.IDPlural == "" || msg.IsOrdinal() {
+			continue
+		}
+		if str, ok := catalog.messages[msg.key()]; ok {
+			if err := catalog.checkPluralEntry(msg.key(), len(str)); err != nil {
+				catalog.skipEntry(err)
+				delete(catalog.messages, msg.key())
+			}
+		}
+	}
+	if catalog.encoding != nil {
+		for key, msgstrs := range catalog.messages {
+			decoded := make([]string, len(msgstrs))
+			for i, s := range msgstrs {
+				decoded[i] = catalog.decode([]byte(s))
+			}
+			catalog.messages[key] = decoded
+		}
+	}
+	catalog.useLanguageRule(catalog.language)
+	return catalog, nil
+}
+
+// ReadPO reads all the entries of a po file, including fuzzy,
+// obsolete and untranslated entries.
+func ReadPO(r io.Reader) ([]Message, error) {
+	p := poParser{s: bufio.NewScanner(r)}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.messages, nil
+}
+
+// maxPOLineLength is the longest line accepted in a po file
+const maxPOLineLength = 1 << 20
+
+// poField identifies the part of an entry a string is appended to.
+type poField int
+
+const (
+	poNone poField = iota
+	poMsgctxt
+	poMsgid
+	poMsgidPlural
+	poMsgstr
+)
+
+type poParser struct {
+	s    *bufio.Scanner
+	line int
+
+	messages []Message
+	msg      Message
+
+	// field is the field that continuation strings are appended
+	// to, and strIndex the msgstr index if that is poMsgstr.
+	field    poField
+	strIndex int
+
+	hasMsgctxt     bool
+	hasMsgid       bool
+	hasMsgidPlural bool
+	hasMsgstr      bool
+
+	// leadByte reports the lead bytes of two byte characters in
+	// the charset of the header, if their second byte can be
+	// mistaken for a backslash.
+	leadByte func(c byte) bool
+}
+
+func (p *poParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *poParser) parse() error {
+	p.s.Buffer(nil, maxPOLineLength)
+	for p.s.Scan() {
+		p.line++
+		line := p.s.Text()
+		if p.line == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if err := p.parseLine(strings.TrimSpace(line)); err != nil {
+			return err
+		}
+	}
+	if err := p.s.Err(); err != nil {
+		return err
+	}
+	return p.finishEntry()
+}
+
+func (p *poParser) parseLine(line string) error {
+	if len(line) == 0 {
+		return p.finishEntry()
+	}
+
+	obsolete := false
+	if strings.HasPrefix(line, "#~") {
+		obsolete = true
+		line = strings.TrimSpace(line[2:])
+		// Previous msgid of an obsolete entry
+		if strings.HasPrefix(line, "|") {
+			return nil
+		}
+	} else if line[0] == '#' {
+		// A comment after msgstr starts a new entry
+		if p.hasMsgstr {
+			if err := p.finishEntry(); err != nil {
+				return err
+			}
+		}
+		p.field = poNone
+		p.parseComment(line)
+		return nil
+	}
+	if len(line) == 0 {
+		return nil
+	}
+
+	if line[0] == '"' {
+		if p.field == poNone {
+			return p.errorf("unexpected string")
+		}
+		s, err := p.parseString(line)
+		if err != nil {
+			return err
+		}
+		p.appendString(s)
+		return nil
+	}
+
+	keyword := line
+	rest := ""
+	if pos := strings.IndexAny(line, " \t"); pos >= 0 {
+		keyword = line[:pos]
+		rest = strings.TrimSpace(line[pos:])
+	}
+	switch {
+	case keyword == "msgctxt":
+		if p.hasMsgstr {
+			if err := p.finishEntry(); err != nil {
+				return err
+			}
+		}
+		if p.hasMsgctxt || p.hasMsgid {
+			return p.errorf("unexpected msgctxt")
+		}
+		p.hasMsgctxt = true
+		p.msg.HasContext = true
+		p.field = poMsgctxt
+	case keyword == "msgid":
+		if p.hasMsgstr {
+			if err := p.finishEntry(); err != nil {
+				return err
+			}
+		}
+		if p.hasMsgid {
+			return p.errorf("missing msgstr")
+		}
+		p.hasMsgid = true
+		p.field = poMsgid
+	case keyword == "msgid_plural":
+		if !p.hasMsgid || p.hasMsgstr || p.hasMsgidPlural {
+			return p.errorf("unexpected msgid_plural")
+		}
+		p.hasMsgidPlural = true
+		p.field = poMsgidPlural
+	case keyword == "msgstr":
+		if !p.hasMsgid || p.hasMsgstr {
+			return p.errorf("unexpected msgstr")
+		}
+		if p.hasMsgidPlural {
+			return p.errorf("plural message requires msgstr[N]")
+		}
+		p.hasMsgstr = true
+		p.field = poMsgstr
+		p.strIndex = 0
+		p.msg.Str = []string{""}
+	case strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]"):
+		index, err := strconv.Atoi(keyword[len("msgstr[") : len(keyword)-1])
+		if err != nil {
+			return p.errorf("invalid msgstr index in %q", keyword)
+		}
+		if !p.hasMsgid || index != len(p.msg.Str) {
+			return p.errorf("unexpected %s", keyword)
+		}
+		if !p.hasMsgidPlural {
+			return p.errorf("%s used without msgid_plural", keyword)
+		}
+		p.hasMsgstr = true
+		p.field = poMsgstr
+		p.strIndex = index
+		p.msg.Str = append(p.msg.Str, "")
+	default:
+		return p.errorf("unknown keyword %q", keyword)
+	}
+	if obsolete {
+		p.msg.Obsolete = true
+	}
+
+	s, err := p.parseString(rest)
+	if err != nil {
+		return err
+	}
+	p.appendString(s)
+	return nil
+}
+
+func (p *poParser) parseComment(line string) {
+	if len(line) == 1 {
+		p.msg.Comments = append(p.msg.Comments, "")
+		return
+	}
+	text := strings.TrimSpace(line[2:])
+	switch line[1] {
+	case '.':
+		p.msg.ExtractedComments = append(p.msg.ExtractedComments, text)
+	case ':':
+		p.msg.References = append(p.msg.References, strings.Fields(text)...)
+	case ',':
+		for _, flag := range strings.Split(text, ",") {
+			if flag = strings.TrimSpace(flag); flag != "" {
+				p.msg.Flags = append(p.msg.Flags, flag)
+			}
+		}
+	case '|':
+		// Previous msgid, used by translation tools only
+	default:
+		p.msg.Comments = append(p.msg.Comments, strings.TrimPrefix(line[1:], " "))
+	}
+}
+
+func (p *poParser) appendString(s string) {
+	switch p.field {
+	case poMsgctxt:
+		p.msg.Context += s
+	case poMsgid:
+		p.msg.ID += s
+	case poMsgidPlural:
+		p.msg.IDPlural += s
+	case poMsgstr:
+		p.msg.Str[p.strIndex] += s
+	}
+}
+
+// finishEntry adds the current entry to the list of messages.
+func (p *poParser) finishEntry() error {
+	if !p.hasMsgid {
+		if p.hasMsgctxt {
+			return p.errorf("missing msgid")
+		}
+		// Any comments read so far belong to the next entry
+		return nil
+	}
+	if !p.hasMsgstr {
+		return p.errorf("missing msgstr")
+	}
+	if p.msg.IsHeader() && !p.msg.Obsolete && len(p.msg.Str) != 0 {
+		p.leadByte = headerLeadByte(p.msg.Str[0])
+	}
+	p.messages = append(p.messages, p.msg)
+	p.msg = Message{}
+	p.field = poNone
+	p.hasMsgctxt = false
+	p.hasMsgid = false
+	p.hasMsgidPlural = false
+	p.hasMsgstr = false
+	return nil
+}
+
+// headerLeadByte returns the multibyteLeadByte function for the
+// charset of a header entry.  Invalid charsets are ignored, as they
+// are reported when the header is read by parsePO.
+func headerLeadByte(header string) func(c byte) bool {
+	fields := parseHeaderFields(header)
+	params, err := parseHeaderParams("Content-Type", fields["content-type"])
+	if err != nil {
+		return nil
+	}
+	enc, err := charsetEncoding(params["charset"])
+	if err != nil {
+		return nil
+	}
+	return multibyteLeadByte(enc)
+}
+
+// parseString decodes a C style quoted string.
+func (p *poParser) parseString(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", p.errorf("invalid string %s", s)
+	}
+	s = s[1 : len(s)-1]
+	if !strings.ContainsAny(s, "\\\"") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if p.leadByte != nil && p.leadByte(c) && i+1 < len(s) {
+			b.WriteByte(c)
+			b.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		if c == '"' {
+			return "", p.errorf("unescaped quote in string")
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", p.errorf("invalid escape at end of string")
+		}
+		switch c = s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\', '"', '\'', '?':
+			b.WriteByte(c)
+		case 'x':
+			j := i + 1
+			for j < len(s) && isHexDigit(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return "", p.errorf("invalid hex escape in string")
+			}
+			v, err := strconv.ParseUint(s[i+1:j], 16, 8)
+			if err != nil {
+				return "", p.errorf("hex escape out of range in string")
+			}
+			b.WriteByte(byte(v))
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			v, err := strconv.ParseUint(s[i:j], 8, 8)
+			if err != nil {
+				return "", p.errorf("octal escape out of range in string")
+			}
+			b.WriteByte(byte(v))
+			i = j - 1
+		default:
+			return "", p.errorf("invalid escape \\%c in string", c)
+		}
+	}
+	return b.String(), nil
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// WritePO writes messages to w in po file format.
+func WritePO(w io.Writer, messages []Message) error {
+	pw := &poWriter{Writer: bufio.NewWriter(w)}
+	for i := range messages {
+		if i != 0 {
+			pw.WriteString("\n")
+		}
+		writePOEntry(pw, &messages[i])
+		if msg := &messages[i]; msg.IsHeader() && !msg.Obsolete && len(msg.Str) != 0 {
+			pw.leadByte = headerLeadByte(msg.Str[0])
+		}
+	}
+	return pw.Flush()
+}
+
+type poWriter struct {
+	*bufio.Writer
+	// leadByte is set as for poParser, so that the second byte
+	// of a character is not escaped.
+	leadByte func(c byte) bool
+}
+
+func writePOEntry(w *poWriter, msg *Message) {
+	for _, comment := range msg.Comments {
+		if comment == "" {
+			w.WriteString("#\n")
+		} else {
+			w.WriteString("# " + comment + "\n")
+		}
+	}
+	for _, comment := range msg.ExtractedComments {
+		w.WriteString("#. " + comment + "\n")
+	}
+	if len(msg.References) != 0 {
+		w.WriteString("#: " + strings.Join(msg.References, " ") + "\n")
+	}
+	if len(msg.Flags) != 0 {
+		w.WriteString("#, " + strings.Join(msg.Flags, ", ") + "\n")
+	}
+
+	prefix := ""
+	if msg.Obsolete {
+		prefix = "#~ "
+	}
+	if msg.hasContext() {
+		writePOString(w, prefix, "msgctxt", msg.Context)
+	}
+	writePOString(w, prefix, "msgid", msg.ID)
+	if msg.IDPlural != "" {
+		writePOString(w, prefix, "msgid_plural", msg.IDPlural)
+		for i, str := range msg.Str {
+			writePOString(w, prefix, fmt.Sprintf("msgstr[%d]", i), str)
+		}
+	} else {
+		str := ""
+		if len(msg.Str) != 0 {
+			str = msg.Str[0]
+		}
+		writePOString(w, prefix, "msgstr", str)
+	}
+}
+
+// writePOString writes a keyword and its quoted value.  Values
+// spanning multiple lines are split after each newline, in the style
+// of the GNU gettext tools.
+func writePOString(w *poWriter, prefix, keyword, value string) {
+	var lines []string
+	for len(value) != 0 {
+		pos := strings.IndexByte(value, '\n')
+		if pos < 0 || pos == len(value)-1 {
+			lines = append(lines, value)
+			break
+		}
+		lines = append(lines, value[:pos+1])
+		value = value[pos+1:]
+	}
+	if len(lines) > 1 {
+		lines = append([]string{""}, lines...)
+	} else if len(lines) == 0 {
+		lines = []string{""}
+	}
+
+	w.WriteString(prefix + keyword + " " + w.quote(lines[0]) + "\n")
+	for _, line := range lines[1:] {
+		w.WriteString(prefix + w.quote(line) + "\n")
+	}
+}
+
+var poEscaper = strings.NewReplacer(poEscapes()...)
+
+// poEscapes returns the replacements made when quoting po strings.
+// Control characters without a named escape are written as octal
+// escapes, which unlike hex escapes have a fixed length.
+func poEscapes() []string {
+	escapes := []string{
+		"\\", "\\\\",
+		"\"", "\\\"",
+		"\n", "\\n",
+		"\t", "\\t",
+		"\r", "\\r",
+		"\a", "\\a",
+		"\b", "\\b",
+		"\f", "\\f",
+		"\v", "\\v",
+	}
+	for c := 0; c < 0x20; c++ {
+		if !strings.ContainsRune("\n\t\r\a\b\f\v", rune(c)) {
+			escapes = append(escapes, string(rune(c)), fmt.Sprintf("\\%03o", c))
+		}
+	}
+	return append(escapes, "\x7f", "\\177")
+}
+
+func (w *poWriter) quote(s string) string {
+	if w.leadByte == nil {
+		return "\"" + poEscaper.Replace(s) + "\""
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s)-1; i++ {
+		if w.leadByte(s[i]) {
+			b.WriteString(poEscaper.Replace(s[start:i]))
+			b.WriteString(s[i : i+2])
+			i++
+			start = i + 1
+		}
+	}
+	b.WriteString(poEscaper.Replace(s[start:]))
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,271 @@
+package gettext
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadPO(t *testing.T) {
+	messages, err := ReadPO(strings.NewReader(`# Translator comment
+#
+msgid ""
+msgstr ""
+"Language: de\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+#. TRANSLATORS: extracted
+#: main.go:10 main.go:20
+#, fuzzy, c-format
+msgctxt "ctx"
+msgid "multi"
+"line"
+msgstr "mehr\tzeilig \"\x41\101\\\n"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d Datei"
+msgstr[1] ""
+"%d Dateien"
+#~ msgid "obsolete"
+#~ msgstr "veraltet"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, len(messages), 4)
+
+	assertDeepEqual(t, messages[0].Comments, []string{"Translator comment", ""})
+	assert_equal(t, messages[0].Str[0], "Language: de\nPlural-Forms: nplurals=2; plural=(n != 1);\n")
+	assertDeepEqual(t, messages[0].IsHeader(), true)
+
+	assertDeepEqual(t, messages[1], Message{
+		Context:           "ctx",
+		HasContext:        true,
+		ID:                "multiline",
+		Str:               []string{"mehr\tzeilig \"AA\\\n"},
+		ExtractedComments: []string{"TRANSLATORS: extracted"},
+		References:        []string{"main.go:10", "main.go:20"},
+		Flags:             []string{"fuzzy", "c-format"},
+	})
+
+	assert_equal(t, messages[2].ID, "%d file")
+	assert_equal(t, messages[2].IDPlural, "%d files")
+	assertDeepEqual(t, messages[2].Str, []string{"%d Datei", "%d Dateien"})
+
+	assertDeepEqual(t, messages[3], Message{
+		ID:       "obsolete",
+		Str:      []string{"veraltet"},
+		Obsolete: true,
+	})
+}
+
+func TestReadPOErrors(t *testing.T) {
+	for _, test := range []struct {
+		po  string
+		err string
+	}{
+		{"msgid \"a\"\n\nmsgid \"b\"\nmsgstr \"\"\n", "line 2: missing msgstr"},
+		{"msgid \"a\"\nmsgid \"b\"\n", "line 2: missing msgstr"},
+		{"msgstr \"a\"\n", "line 1: unexpected msgstr"},
+		{"\"a\"\n", "line 1: unexpected string"},
+		{"msgid a\n", "line 1: invalid string a"},
+		{"msgid \"a\\q\"\n", "line 1: invalid escape \\q in string"},
+		{"msgid \"a\"\nmsgid_plural \"b\"\nmsgstr \"c\"\n", "line 3: plural message requires msgstr[N]"},
+		{"msgid \"a\"\nmsgid_plural \"b\"\nmsgstr[1] \"c\"\n", "line 3: unexpected msgstr[1]"},
+		{"msgid \"a\"\nmsgstr[0] \"c\"\n", "line 2: msgstr[0] used without msgid_plural"},
+		{"msgctxt \"a\"\n\n", "line 2: missing msgid"},
+		{"msgfoo \"a\"\n", "line 1: unknown keyword \"msgfoo\""},
+	} {
+		_, err := ReadPO(strings.NewReader(test.po))
+		if err == nil {
+			t.Errorf("expected error parsing %q", test.po)
+			continue
+		}
+		assert_equal(t, err.Error(), test.err)
+	}
+}
+
+func TestParsePOSkipsUnusedEntries(t *testing.T) {
+	catalog, err := ParsePO(strings.NewReader(`msgid ""
+msgstr "Plural-Forms: nplurals=1; plural=0;\n"
+
+#, fuzzy
+msgid "fuzzy"
+msgstr "translated"
+
+msgid "untranslated"
+msgstr ""
+
+#~ msgid "obsolete"
+#~ msgstr "translated"
+
+msgid "%d thing"
+msgid_plural "%d things"
+msgstr[0] "%d Ding"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Gettext("fuzzy"), "fuzzy")
+	assert_equal(t, catalog.Gettext("untranslated"), "untranslated")
+	assert_equal(t, catalog.Gettext("obsolete"), "obsolete")
+	// The header's plural forms are used
+	assert_equal(t, catalog.NGettext("%d thing", "%d things", 5), "%d Ding")
+}
+
+// TestParsePOMatchesMO checks that each po file in testdata gives the
+// same translations as the mo file compiled from it.
+func TestParsePOMatchesMO(t *testing.T) {
+	for _, lang := range []string{"en", "en-no-plural-forms", "en_AU", "es", "ja"} {
+		lang := lang
+		t.Run(lang, func(t *testing.T) {
+			f, err := os.Open("testdata/" + lang + "/messages.po")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			messages, err := ReadPO(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.Seek(0, 0); err != nil {
+				t.Fatal(err)
+			}
+			po, err := parsePO(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			moFile, err := os.Open("testdata/" + lang + "/messages.mo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer moFile.Close()
+			mo, err := parseMO(moFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertDeepEqual(t, po.info, mo.info)
+			for _, msg := range messages {
+				for n := uint64(0); n < 5; n++ {
+					f := form{n: n}
+					if msg.IDPlural != "" {
+						f.kind = pluralForm
+					}
+					poStr, poOk := po.findMsg(msg.key(), f)
+					moStr, moOk := mo.findMsg(msg.key(), f)
+					assertDeepEqual(t, poOk, moOk)
+					assert_equal(t, poStr, moStr)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePOEmptyContext(t *testing.T) {
+	const po = `msgid ""
+msgstr "Content-Type: text/plain; charset=UTF-8\n"
+
+msgctxt ""
+msgid "a"
+msgstr "empty context"
+
+msgid "a"
+msgstr "no context"
+`
+	messages, err := ReadPO(strings.NewReader(po))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, len(messages), 3)
+	assertDeepEqual(t, messages[0].IsHeader(), true)
+	assertDeepEqual(t, messages[1].HasContext, true)
+	assertDeepEqual(t, messages[1].IsHeader(), false)
+	assertDeepEqual(t, messages[2].HasContext, false)
+
+	catalog, err := ParsePO(strings.NewReader(po))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.PGettext("", "a"), "empty context")
+	assert_equal(t, catalog.Gettext("a"), "no context")
+
+	// The empty context is written back
+	var buf strings.Builder
+	if err := WritePO(&buf, messages); err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, buf.String(), po)
+}
+
+func TestWritePO(t *testing.T) {
+	messages := []Message{
+		{ID: "", Str: []string{"Language: de\nPlural-Forms: nplurals=2; plural=(n != 1);\n"}, Comments: []string{"Header", ""}},
+		{
+			Context:           "ctx",
+			HasContext:        true,
+			ID:                "tab\tquote\"backslash\\",
+			Str:               []string{"first line\nsecond line"},
+			ExtractedComments: []string{"TRANSLATORS: note"},
+			References:        []string{"a.go:1", "b.go:2"},
+			Flags:             []string{"fuzzy", "go-format"},
+		},
+		{ID: "%d file", IDPlural: "%d files", Str: []string{"%d Datei", "%d Dateien"}},
+		{ID: "old", Str: []string{"alt"}, Obsolete: true},
+	}
+	var buf strings.Builder
+	if err := WritePO(&buf, messages); err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, buf.String(), `# Header
+#
+msgid ""
+msgstr ""
+"Language: de\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+#. TRANSLATORS: note
+#: a.go:1 b.go:2
+#, fuzzy, go-format
+msgctxt "ctx"
+msgid "tab\tquote\"backslash\\"
+msgstr ""
+"first line\n"
+"second line"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d Datei"
+msgstr[1] "%d Dateien"
+
+#~ msgid "old"
+#~ msgstr "alt"
+`)
+
+	// The written file reads back to the same messages
+	readBack, err := ReadPO(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, readBack, messages)
+}
+
+func TestWritePOControlCharacters(t *testing.T) {
+	messages := []Message{
+		{ID: "bell\a", Str: []string{"\x1b[1mbold\x1b[0m\x01\x7f9"}},
+	}
+	var buf strings.Builder
+	if err := WritePO(&buf, messages); err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, buf.String(), `msgid "bell\a"
+msgstr "\033[1mbold\033[0m\001\1779"
+`)
+	readBack, err := ReadPO(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, readBack, messages)
+}